@@ -3,7 +3,11 @@ package gmar
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"strings"
 
 	"github.com/everFinance/goar"
@@ -13,6 +17,8 @@ type ArweaveClient struct {
 	Node   string
 	Wallet *goar.Wallet
 	Client *goar.Client
+
+	RewardMultiplier float64 // applied to the reward quoted by the node, 1 when zero
 }
 
 const (
@@ -22,13 +28,23 @@ const (
 )
 
 func InitArweaveClient(keyFile, node string) (*ArweaveClient, error) {
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile err %v", err)
+	}
+
+	return InitArweaveClientFromBytes(key, node)
+}
+
+// Create a client from the content of a JWK keyfile
+func InitArweaveClientFromBytes(key []byte, node string) (*ArweaveClient, error) {
 	if strings.EqualFold(node, "") {
 		node = "https://arweave.net/"
 	}
 
-	wallet, err := goar.NewWalletFromPath(keyFile, node)
+	wallet, err := goar.NewWallet(key, node)
 	if err != nil {
-		return nil, fmt.Errorf("goar.NewWalletFromPath err %v", err)
+		return nil, fmt.Errorf("goar.NewWallet err %v", err)
 	}
 
 	_arweave := &ArweaveClient{
@@ -74,3 +90,36 @@ func (a *ArweaveClient) NewManifest(path string) (*ArweaveManifest, error) {
 
 	return manifest, nil
 }
+
+// GET a path relative to the node, non 2xx responses are mapped to the goar errors
+func (a *ArweaveClient) httpGet(p string) ([]byte, error) {
+	u, err := url.Parse(a.Node)
+	if err != nil {
+		return nil, fmt.Errorf("url.Parse err %v", err)
+	}
+	u.Path = path.Join(u.Path, p)
+
+	rsp, err := http.Get(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("http.Get err %v", err)
+	}
+	defer rsp.Body.Close()
+
+	body, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll err %v", err)
+	}
+
+	switch rsp.StatusCode {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusAccepted:
+		return nil, goar.ErrPendingTx
+	case http.StatusNotFound:
+		return nil, goar.ErrNotFound
+	case http.StatusTooManyRequests:
+		return nil, goar.ErrRequestLimit
+	default:
+		return nil, fmt.Errorf("get %s status %d, body %s", p, rsp.StatusCode, body)
+	}
+}
@@ -0,0 +1,242 @@
+package gmar
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	gm "github.com/W3Tools/go-modules"
+	"github.com/everFinance/goar"
+	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goar/utils"
+)
+
+var ErrFileHashMismatch = errors.New("file hash mismatch")
+
+// Returned when downloaded bytes do not match the FileHash tag of the transaction, wraps ErrFileHashMismatch
+type FileHashMismatchError struct {
+	TxID     string
+	Expected string
+	Actual   string
+}
+
+func (e *FileHashMismatchError) Error() string {
+	return fmt.Sprintf("%v, tx %s, expected %s, got %s", ErrFileHashMismatch, e.TxID, e.Expected, e.Actual)
+}
+
+func (e *FileHashMismatchError) Unwrap() error {
+	return ErrFileHashMismatch
+}
+
+// Decoded tags of the transaction
+func (a *ArweaveClient) GetTags(id string) ([]types.Tag, error) {
+	tags, err := a.Client.GetTransactionTags(id)
+	if err != nil {
+		return nil, fmt.Errorf("client.GetTransactionTags err %v", err)
+	}
+	return tags, nil
+}
+
+// Value of the named tag, an empty string when the transaction does not carry it
+func (a *ArweaveClient) GetTag(id, name string) (string, error) {
+	tags, err := a.GetTags(id)
+	if err != nil {
+		return "", err
+	}
+	return findTag(tags, name), nil
+}
+
+// Download the transaction data, falling back to chunks when the node refuses to serve it at once
+func (a *ArweaveClient) Download(id string) ([]byte, error) {
+	body, err := a.httpGet(fmt.Sprintf("tx/%s/data", id))
+	if err == nil && len(body) > 0 {
+		data, err := utils.Base64Decode(string(body))
+		if err != nil {
+			return nil, fmt.Errorf("utils.Base64Decode err %v", err)
+		}
+		return data, nil
+	}
+
+	if err != nil && (errors.Is(err, goar.ErrNotFound) || errors.Is(err, goar.ErrPendingTx) || errors.Is(err, goar.ErrRequestLimit)) {
+		return nil, err
+	}
+
+	// Nodes answer 400 for items bigger than 12MiB, those have to be downloaded chunk by chunk
+	var buffer bytes.Buffer
+	if _, err := a.DownloadChunks(id, &buffer); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Stream the transaction data to w chunk by chunk, suitable for items of any size
+func (a *ArweaveClient) DownloadChunks(id string, w io.Writer) (int64, error) {
+	body, err := a.httpGet(fmt.Sprintf("tx/%s/offset", id))
+	if err != nil {
+		return 0, err
+	}
+
+	offset := new(types.TransactionOffset)
+	if err := json.Unmarshal(body, offset); err != nil {
+		return 0, fmt.Errorf("json.Unmarshal err %v", err)
+	}
+
+	size, err := strconv.ParseInt(offset.Size, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid offset size %s", offset.Size)
+	}
+	endOffset, err := strconv.ParseInt(offset.Offset, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid offset %s", offset.Offset)
+	}
+
+	startOffset := endOffset - size + 1
+	var written int64
+	for written < size {
+		body, err := a.httpGet(fmt.Sprintf("chunk/%d", startOffset+written))
+		if err != nil {
+			return written, fmt.Errorf("download chunk at %d err %v", startOffset+written, err)
+		}
+
+		chunk := new(types.TransactionChunk)
+		if err := json.Unmarshal(body, chunk); err != nil {
+			return written, fmt.Errorf("json.Unmarshal err %v", err)
+		}
+
+		data, err := utils.Base64Decode(chunk.Chunk)
+		if err != nil {
+			return written, fmt.Errorf("utils.Base64Decode err %v", err)
+		}
+		if len(data) == 0 {
+			return written, fmt.Errorf("empty chunk at %d", startOffset+written)
+		}
+
+		n, err := w.Write(data)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// Download the transaction data into filePath, the file hash is verified when the transaction carries a FileHash tag.
+// The file is removed when the download or the verification fails, unverified bytes are never left at filePath
+func (a *ArweaveClient) DownloadFile(id, filePath string) (err error) {
+	// Manifests and transactions from other uploaders do not carry the tag
+	expected, err := a.GetTag(id, "FileHash")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("os.Create err %v", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("f.Close err %v", closeErr)
+		}
+		if err != nil {
+			os.Remove(filePath)
+		}
+	}()
+
+	hash := sha256.New()
+	if _, err := a.DownloadChunks(id, io.MultiWriter(f, hash)); err != nil {
+		return err
+	}
+
+	if expected == "" {
+		return nil
+	}
+	return compareFileHash(id, expected, fmt.Sprintf("%x", hash.Sum(nil)))
+}
+
+// Download the transaction data and verify it against the FileHash tag written by GetTransaction
+func (a *ArweaveClient) DownloadVerified(id string) ([]byte, error) {
+	data, err := a.Download(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.VerifyFileHash(id, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Compare data with the FileHash tag of the transaction, transactions without the tag cannot be verified
+func (a *ArweaveClient) VerifyFileHash(id string, data []byte) error {
+	actual, err := gm.ReadFileHash(data)
+	if err != nil {
+		return err
+	}
+	return a.verifyFileHash(id, actual)
+}
+
+func (a *ArweaveClient) verifyFileHash(id, actual string) error {
+	expected, err := a.GetTag(id, "FileHash")
+	if err != nil {
+		return err
+	}
+
+	if expected == "" {
+		return fmt.Errorf("tx %s has no FileHash tag", id)
+	}
+	return compareFileHash(id, expected, actual)
+}
+
+func compareFileHash(id, expected, actual string) error {
+	if !strings.EqualFold(expected, actual) {
+		return &FileHashMismatchError{TxID: id, Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// Download and decode a manifest uploaded with the manifest content type
+func (a *ArweaveClient) GetManifest(id string) (*ArweaveManifest, error) {
+	data, err := a.Download(id)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := NewManifest()
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal manifest err %v", err)
+	}
+	return manifest, nil
+}
+
+// Resolve a path of the manifest to its transaction id, an empty path resolves the index
+func (a *ArweaveClient) ResolveManifestPath(manifestID, p string) (string, error) {
+	manifest, err := a.GetManifest(manifestID)
+	if err != nil {
+		return "", err
+	}
+	return manifest.Resolve(p)
+}
+
+// Download the content a path of the manifest points to
+func (a *ArweaveClient) DownloadManifestPath(manifestID, p string) ([]byte, error) {
+	id, err := a.ResolveManifestPath(manifestID, p)
+	if err != nil {
+		return nil, err
+	}
+	return a.Download(id)
+}
+
+func findTag(tags []types.Tag, name string) string {
+	for _, tag := range tags {
+		if strings.EqualFold(tag.Name, name) {
+			return tag.Value
+		}
+	}
+	return ""
+}
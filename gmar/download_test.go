@@ -0,0 +1,121 @@
+package gmar
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gm "github.com/W3Tools/go-modules"
+	"github.com/everFinance/goar/types"
+)
+
+func TestDownload(t *testing.T) {
+	node := &testArweaveNode{}
+	client := newTestArweaveClient(t, node)
+
+	small := []byte("Hello, GO Modules!")
+	large := randomBytes(t, 700*1024)
+	node.addItem("small", small)
+	node.addItem("large", large)
+
+	data, err := client.Download("small")
+	if err != nil || !bytes.Equal(data, small) {
+		t.Errorf("expected %s, but got %s err %v", small, data, err)
+	}
+
+	// Served with 400 by the data endpoint, must fall back to chunks
+	data, err = client.Download("large")
+	if err != nil || !bytes.Equal(data, large) {
+		t.Errorf("expected %d bytes from chunks, but got %d err %v", len(large), len(data), err)
+	}
+
+	if _, err := client.Download("missing"); err == nil {
+		t.Error("expected an error, but got nil")
+	}
+}
+
+func TestDownloadVerified(t *testing.T) {
+	node := &testArweaveNode{}
+	client := newTestArweaveClient(t, node)
+
+	data := randomBytes(t, 400*1024)
+	hash, _ := gm.ReadFileHash(data)
+	node.addItem("good", data, types.Tag{Name: "FileHash", Value: hash})
+	node.addItem("bad", data, types.Tag{Name: "FileHash", Value: "00"})
+
+	if _, err := client.DownloadVerified("good"); err != nil {
+		t.Errorf("DownloadVerified err, msg: %v", err)
+	}
+
+	_, err := client.DownloadVerified("bad")
+	var mismatch *FileHashMismatchError
+	if !errors.Is(err, ErrFileHashMismatch) || !errors.As(err, &mismatch) || mismatch.Actual != hash {
+		t.Errorf("expected a file hash mismatch, but got %v", err)
+	}
+
+	filePath := filepath.Join(t.TempDir(), "good.bin")
+	if err := client.DownloadFile("good", filePath); err != nil {
+		t.Fatalf("DownloadFile err, msg: %v", err)
+	}
+	if written, _ := os.ReadFile(filePath); !bytes.Equal(written, data) {
+		t.Error("expected downloaded file to match the data")
+	}
+
+	filePath = filepath.Join(t.TempDir(), "bad.bin")
+	if err := client.DownloadFile("bad", filePath); !errors.Is(err, ErrFileHashMismatch) {
+		t.Errorf("expected a file hash mismatch, but got %v", err)
+	}
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Errorf("expected the unverified file to be removed, but got %v", err)
+	}
+}
+
+func TestDownloadFileWithoutFileHash(t *testing.T) {
+	node := &testArweaveNode{}
+	client := newTestArweaveClient(t, node)
+
+	// Manifests are uploaded without the FileHash tag
+	manifestData, _ := NewManifest().Marshal()
+	node.addItem("manifest", manifestData)
+
+	filePath := filepath.Join(t.TempDir(), "manifest.json")
+	if err := client.DownloadFile("manifest", filePath); err != nil {
+		t.Fatalf("DownloadFile err, msg: %v", err)
+	}
+	if written, _ := os.ReadFile(filePath); !bytes.Equal(written, manifestData) {
+		t.Error("expected downloaded file to match the manifest")
+	}
+
+	if _, err := client.DownloadVerified("manifest"); err == nil {
+		t.Error("expected DownloadVerified to refuse a transaction without FileHash, but got nil")
+	}
+}
+
+func TestDownloadManifestPath(t *testing.T) {
+	node := &testArweaveNode{}
+	client := newTestArweaveClient(t, node)
+
+	manifest := NewManifest()
+	manifest.Index.Path = IndexFile
+	manifest.Paths[IndexFile] = ArweaveManifestPath{ID: "index"}
+	manifest.Paths["docs/index.html"] = ArweaveManifestPath{ID: "docs"}
+	manifestData, _ := manifest.Marshal()
+
+	node.addItem("manifest", manifestData)
+	node.addItem("index", []byte("<html>index</html>"))
+	node.addItem("docs", []byte("<html>docs</html>"))
+
+	tests := map[string]string{"": "<html>index</html>", "/docs": "<html>docs</html>", "docs/index.html": "<html>docs</html>"}
+	for p, expected := range tests {
+		data, err := client.DownloadManifestPath("manifest", p)
+		if err != nil || string(data) != expected {
+			t.Errorf("path %q expected %s, but got %s err %v", p, expected, data, err)
+		}
+	}
+
+	if _, err := client.ResolveManifestPath("manifest", "missing.html"); err == nil {
+		t.Error("expected an error, but got nil")
+	}
+}
@@ -1,8 +1,11 @@
 package gmar
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"path"
+	"strings"
 )
 
 type ArweaveManifest struct {
@@ -29,6 +32,30 @@ func NewManifest() *ArweaveManifest {
 	}
 }
 
+func (m *ArweaveManifest) Marshal() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// Transaction id of the path, an empty path resolves the index
+func (m *ArweaveManifest) Resolve(p string) (string, error) {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		if m.Index.Path == "" {
+			return "", fmt.Errorf("manifest has no index")
+		}
+		p = m.Index.Path
+	}
+
+	if item, ok := m.Paths[p]; ok {
+		return item.ID, nil
+	}
+
+	if item, ok := m.Paths[path.Join(p, IndexFile)]; ok {
+		return item.ID, nil
+	}
+	return "", fmt.Errorf("path %s not found in manifest", p)
+}
+
 func WriteManifest(name string, data []byte) error {
 	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
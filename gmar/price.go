@@ -0,0 +1,200 @@
+package gmar
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"math/big"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const WinstonPerAR = 1000000000000
+
+var ErrInsufficientBalance = errors.New("insufficient wallet balance")
+
+// Estimated reward of a single upload, the multiplier is already applied
+type UploadCostItem struct {
+	Path    string
+	Size    int64
+	Winston *big.Int
+}
+
+type UploadCost struct {
+	Items   []UploadCostItem
+	Bytes   int64
+	Winston *big.Int
+}
+
+func (c *UploadCost) AR() *big.Float {
+	return WinstonToAR(c.Winston)
+}
+
+// 1 Winston = 0.000000000001 AR
+func WinstonToAR(winston *big.Int) *big.Float {
+	return new(big.Float).SetPrec(128).Quo(
+		new(big.Float).SetPrec(128).SetInt(winston),
+		new(big.Float).SetPrec(128).SetUint64(WinstonPerAR),
+	)
+}
+
+// 1 AR = 1000000000000 Winston, rounded to the nearest Winston, infinite and negative amounts are rejected
+func ARToWinston(ar *big.Float) (*big.Int, error) {
+	if ar == nil || ar.IsInf() {
+		return nil, fmt.Errorf("invalid ar amount %v", ar)
+	}
+	if ar.Sign() < 0 {
+		return nil, fmt.Errorf("negative ar amount %v", ar)
+	}
+
+	rat, _ := ar.Rat(nil)
+	rat.Mul(rat, new(big.Rat).SetInt64(WinstonPerAR))
+
+	winston, _ := new(big.Int).SetString(rat.FloatString(0), 10)
+	return winston, nil
+}
+
+/*
+Parse a decimal AR amount into Winston without floating point rounding
+
+	winston, err := gmar.ParseAR("0.5")
+	Output: 500000000000
+*/
+func ParseAR(amount string) (*big.Int, error) {
+	ar, ok := new(big.Rat).SetString(strings.TrimSpace(amount))
+	if !ok {
+		return nil, fmt.Errorf("invalid ar amount %q", amount)
+	}
+
+	winston := new(big.Rat).Mul(ar, new(big.Rat).SetInt64(WinstonPerAR))
+	return new(big.Int).Quo(winston.Num(), winston.Denom()), nil
+}
+
+/*
+Format a Winston amount as a decimal AR string, trailing zeros are trimmed
+
+	gmar.FormatAR(big.NewInt(1500000000000))
+	Output: 1.5
+*/
+func FormatAR(winston *big.Int) string {
+	ar := new(big.Rat).SetFrac(winston, big.NewInt(WinstonPerAR)).FloatString(12)
+	ar = strings.TrimRight(ar, "0")
+	return strings.TrimSuffix(ar, ".")
+}
+
+// Reward returned by the node multiplied by ArweaveClient.RewardMultiplier
+func (a *ArweaveClient) GetReward(dataSize int64) (*big.Int, error) {
+	reward, err := a.Client.GetTransactionPrice(int(dataSize), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return big.NewInt(a.applyRewardMultiplier(reward)), nil
+}
+
+func (a *ArweaveClient) applyRewardMultiplier(reward int64) int64 {
+	if a.RewardMultiplier <= 0 {
+		return reward
+	}
+	return int64(math.Ceil(float64(reward) * a.RewardMultiplier))
+}
+
+// Wallet balance in Winston, read from the node without converting through AR
+func (a *ArweaveClient) GetWinstonBalance() (*big.Int, error) {
+	body, err := a.httpGet(path.Join("wallet", a.Wallet.Signer.Address, "balance"))
+	if err != nil {
+		return nil, err
+	}
+
+	balance, ok := new(big.Int).SetString(strings.TrimSpace(string(body)), 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid balance %s", body)
+	}
+	return balance, nil
+}
+
+// Refuse to continue when the wallet cannot pay the reward, the returned error wraps ErrInsufficientBalance
+func (a *ArweaveClient) CheckBalance(winston *big.Int) error {
+	balance, err := a.GetWinstonBalance()
+	if err != nil {
+		return err
+	}
+
+	if balance.Cmp(winston) < 0 {
+		return fmt.Errorf("%w, balance %s AR, required %s AR", ErrInsufficientBalance, FormatAR(balance), FormatAR(winston))
+	}
+	return nil
+}
+
+// Estimate the reward of uploading a single file
+func (a *ArweaveClient) EstimateFileCost(filePath string) (*UploadCost, error) {
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	cost := &UploadCost{Winston: new(big.Int)}
+	if err := a.addCostItem(cost, filepath.Base(filePath), stat.Size()); err != nil {
+		return nil, err
+	}
+	return cost, nil
+}
+
+// Estimate the reward of uploading every file under dir plus the manifest referencing them
+func (a *ArweaveClient) EstimateDirCost(dir string) (*UploadCost, error) {
+	cost := &UploadCost{Winston: new(big.Int)}
+	manifest := NewManifest()
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		// Transaction ids are always 43 characters, a placeholder gives the exact manifest size
+		manifest.Paths[rel] = ArweaveManifestPath{ID: strings.Repeat("x", 43)}
+		if rel == IndexFile {
+			manifest.Index.Path = IndexFile
+		}
+
+		return a.addCostItem(cost, rel, info.Size())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := manifest.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.addCostItem(cost, ManifestFile, int64(len(data))); err != nil {
+		return nil, err
+	}
+	return cost, nil
+}
+
+func (a *ArweaveClient) addCostItem(cost *UploadCost, name string, size int64) error {
+	reward, err := a.GetReward(size)
+	if err != nil {
+		return fmt.Errorf("a.GetReward %s err %v", name, err)
+	}
+
+	cost.Items = append(cost.Items, UploadCostItem{Path: name, Size: size, Winston: reward})
+	cost.Bytes += size
+	cost.Winston.Add(cost.Winston, reward)
+	return nil
+}
@@ -0,0 +1,85 @@
+package gmar
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestARConversion(t *testing.T) {
+	winston, err := ParseAR("1.000000000001")
+	if err != nil {
+		t.Fatalf("ParseAR err, msg: %v", err)
+	}
+	if winston.String() != "1000000000001" {
+		t.Errorf("expected 1000000000001, but got %v", winston)
+	}
+
+	if got := FormatAR(big.NewInt(1500000000000)); got != "1.5" {
+		t.Errorf("expected 1.5, but got %v", got)
+	}
+	if got := FormatAR(big.NewInt(2 * WinstonPerAR)); got != "2" {
+		t.Errorf("expected 2, but got %v", got)
+	}
+
+	if got, err := ARToWinston(WinstonToAR(big.NewInt(123456789))); err != nil || got.Int64() != 123456789 {
+		t.Errorf("expected 123456789, but got %v %v", got, err)
+	}
+	for _, ar := range []*big.Float{new(big.Float).SetInf(false), big.NewFloat(-1), nil} {
+		if _, err := ARToWinston(ar); err == nil {
+			t.Errorf("expected an error for %v, but got nil", ar)
+		}
+	}
+
+	if _, err := ParseAR("abc"); err == nil {
+		t.Error("expected an error, but got nil")
+	}
+}
+
+func TestEstimateDirCost(t *testing.T) {
+	client := newTestArweaveClient(t, &testArweaveNode{})
+	client.RewardMultiplier = 1.5
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, IndexFile), make([]byte, 100), 0644)
+	os.MkdirAll(filepath.Join(dir, "assets"), 0755)
+	os.WriteFile(filepath.Join(dir, "assets", "app.js"), make([]byte, 300), 0644)
+
+	cost, err := client.EstimateDirCost(dir)
+	if err != nil {
+		t.Fatalf("EstimateDirCost err, msg: %v", err)
+	}
+
+	if len(cost.Items) != 3 || cost.Items[2].Path != ManifestFile {
+		t.Fatalf("expected two files and the manifest, but got %+v", cost.Items)
+	}
+
+	// The test node quotes 1000 + size Winston
+	expected := int64(0)
+	for _, item := range cost.Items {
+		expected += ((1000+item.Size)*3 + 1) / 2
+	}
+	if cost.Winston.Int64() != expected || cost.Bytes < 400 {
+		t.Errorf("expected %d Winston, but got %v for %d bytes", expected, cost.Winston, cost.Bytes)
+	}
+}
+
+func TestUploadRefusedOnInsufficientBalance(t *testing.T) {
+	node := &testArweaveNode{balance: "10"}
+	client := newTestArweaveClient(t, node)
+
+	if _, err := client.UploadTo([]byte("Hello, GO Modules!"), false); !errors.Is(err, ErrInsufficientBalance) {
+		t.Errorf("expected ErrInsufficientBalance, but got %v", err)
+	}
+
+	if _, err := client.NewUploader(bytes.NewReader([]byte("Hello, GO Modules!")), nil); !errors.Is(err, ErrInsufficientBalance) {
+		t.Errorf("expected ErrInsufficientBalance, but got %v", err)
+	}
+
+	if len(node.txs) != 0 {
+		t.Errorf("expected no transaction to be posted, but got %d", len(node.txs))
+	}
+}
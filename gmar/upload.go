@@ -2,6 +2,7 @@ package gmar
 
 import (
 	"fmt"
+	"math/big"
 	"net/url"
 	"path"
 
@@ -15,6 +16,11 @@ func (a *ArweaveClient) UploadTo(data []byte, manifest bool) (*types.Transaction
 		return nil, err
 	}
 
+	err = a.checkTransactionBalance(tx)
+	if err != nil {
+		return nil, err
+	}
+
 	uploder, err := goar.CreateUploader(a.Client, tx, nil)
 	if err != nil {
 		return nil, fmt.Errorf("goar.CreateUploader err %v", err)
@@ -51,3 +57,12 @@ func (a *ArweaveClient) UploadToUrl(data []byte, manifest bool) (string, error)
 
 	return u.String(), nil
 }
+
+func (a *ArweaveClient) checkTransactionBalance(tx *types.Transaction) error {
+	reward, ok := new(big.Int).SetString(tx.Reward, 10)
+	if !ok {
+		return fmt.Errorf("invalid transaction reward %s", tx.Reward)
+	}
+
+	return a.CheckBalance(reward)
+}
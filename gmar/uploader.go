@@ -0,0 +1,369 @@
+package gmar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goar/utils"
+)
+
+const (
+	DefaultChunkMaxRetries = 5
+	DefaultChunkRetryDelay = time.Second
+)
+
+// Snapshot of an upload, reported to UploadOptions.OnProgress after every step
+type UploadProgress struct {
+	TxID           string
+	UploadedChunks int
+	TotalChunks    int
+	UploadedBytes  int64
+	TotalBytes     int64
+}
+
+type UploadOptions struct {
+	Manifest   bool                          // tag the data as an arweave manifest
+	MaxRetries int                           // attempts per chunk, DefaultChunkMaxRetries when zero
+	RetryDelay time.Duration                 // first retry delay, doubled on every attempt, DefaultChunkRetryDelay when zero
+	StateFile  string                        // persist the uploader state here after every step, removed once complete
+	OnProgress func(progress UploadProgress) // optional progress callback
+}
+
+// Serializable state of an upload, enough to resume it after a process restart together with the original data
+type UploaderState struct {
+	Transaction *types.Transaction `json:"transaction"`
+	ChunkIndex  int                `json:"chunkIndex"`
+	TxPosted    bool               `json:"txPosted"`
+}
+
+type ArweaveUploader struct {
+	client  *ArweaveClient
+	options UploadOptions
+	file    *os.File
+	temp    bool
+	tx      *types.Transaction
+	state   *UploaderState
+}
+
+/*
+Create a chunked uploader reading the data from the file
+
+	uploader, err := client.NewFileUploader("large.bin", &gmar.UploadOptions{StateFile: "large.bin.upload"})
+	if err != nil {
+		return err
+	}
+	defer uploader.Close()
+
+	tx, err := uploader.Upload(ctx)
+*/
+func (a *ArweaveClient) NewFileUploader(filePath string, options *UploadOptions) (*ArweaveUploader, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open err %v", err)
+	}
+
+	return a.newUploader(file, false, nil, options)
+}
+
+// Create a chunked uploader reading the data from r.
+// The data root must be known before the transaction is signed, so r is spooled to a temporary file first.
+func (a *ArweaveClient) NewUploader(r io.Reader, options *UploadOptions) (*ArweaveUploader, error) {
+	file, err := spoolTempFile(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.newUploader(file, true, nil, options)
+}
+
+// Resume an interrupted upload from its state and the original file
+func (a *ArweaveClient) ResumeFileUploader(state *UploaderState, filePath string, options *UploadOptions) (*ArweaveUploader, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open err %v", err)
+	}
+
+	return a.newUploader(file, false, state, options)
+}
+
+// Resume an interrupted upload from its state and the original data
+func (a *ArweaveClient) ResumeUploader(state *UploaderState, r io.Reader, options *UploadOptions) (*ArweaveUploader, error) {
+	file, err := spoolTempFile(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.newUploader(file, true, state, options)
+}
+
+// Read an uploader state previously written to UploadOptions.StateFile
+func LoadUploaderState(path string) (*UploaderState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	state := new(UploaderState)
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal err %v", err)
+	}
+
+	if state.Transaction == nil {
+		return nil, fmt.Errorf("uploader state without transaction")
+	}
+	return state, nil
+}
+
+func (a *ArweaveClient) newUploader(file *os.File, temp bool, state *UploaderState, options *UploadOptions) (*ArweaveUploader, error) {
+	uploader := &ArweaveUploader{client: a, file: file, temp: temp}
+	if err := uploader.init(state, options); err != nil {
+		uploader.Close()
+		return nil, err
+	}
+	return uploader, nil
+}
+
+func (u *ArweaveUploader) init(state *UploaderState, options *UploadOptions) error {
+	if options != nil {
+		u.options = *options
+	}
+	if u.options.MaxRetries <= 0 {
+		u.options.MaxRetries = DefaultChunkMaxRetries
+	}
+	if u.options.RetryDelay <= 0 {
+		u.options.RetryDelay = DefaultChunkRetryDelay
+	}
+
+	if state == nil {
+		tx, err := u.client.GetFileTransaction(u.file, u.options.Manifest)
+		if err != nil {
+			return err
+		}
+
+		if err := u.client.checkTransactionBalance(tx); err != nil {
+			return err
+		}
+
+		u.tx = tx
+		u.state = &UploaderState{Transaction: headerTransaction(tx)}
+		return nil
+	}
+
+	if state.Transaction == nil {
+		return fmt.Errorf("uploader state without transaction")
+	}
+
+	stat, err := u.file.Stat()
+	if err != nil {
+		return fmt.Errorf("file.Stat err %v", err)
+	}
+
+	tx := headerTransaction(state.Transaction)
+	tx.DataReader = u.file
+	tx.DataRoot = ""
+	if err := utils.PrepareChunks(tx, u.file, int(stat.Size())); err != nil {
+		return fmt.Errorf("utils.PrepareChunks err %v", err)
+	}
+
+	if tx.DataRoot != state.Transaction.DataRoot {
+		return fmt.Errorf("data mismatch, data root %s, expected %s", tx.DataRoot, state.Transaction.DataRoot)
+	}
+
+	u.tx = tx
+	u.state = &UploaderState{
+		Transaction: headerTransaction(state.Transaction),
+		ChunkIndex:  state.ChunkIndex,
+		TxPosted:    state.TxPosted,
+	}
+	return nil
+}
+
+// Post the transaction header and every remaining chunk, retrying each step up to UploadOptions.MaxRetries times
+func (u *ArweaveUploader) Upload(ctx context.Context) (*types.Transaction, error) {
+	if !u.state.TxPosted {
+		err := u.retry(ctx, func() (string, int, error) {
+			return u.client.Client.SubmitTransaction(u.state.Transaction)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("submit transaction %s err %v", u.tx.ID, err)
+		}
+
+		u.state.TxPosted = true
+		if err := u.saveState(); err != nil {
+			return nil, err
+		}
+	}
+	u.reportProgress()
+
+	for u.state.ChunkIndex < u.TotalChunks() {
+		chunk, err := utils.GetChunkStream(*u.tx, u.state.ChunkIndex, u.file)
+		if err != nil {
+			return nil, fmt.Errorf("utils.GetChunkStream err %v", err)
+		}
+
+		err = u.retry(ctx, func() (string, int, error) {
+			return u.client.Client.SubmitChunks(chunk)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("submit chunk %d/%d err %v", u.state.ChunkIndex+1, u.TotalChunks(), err)
+		}
+
+		u.state.ChunkIndex++
+		if err := u.saveState(); err != nil {
+			return nil, err
+		}
+		u.reportProgress()
+	}
+
+	if u.options.StateFile != "" {
+		if err := os.Remove(u.options.StateFile); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("os.Remove err %v", err)
+		}
+	}
+
+	return u.tx, nil
+}
+
+// Copy of the current state, safe to serialize with encoding/json
+func (u *ArweaveUploader) State() *UploaderState {
+	return &UploaderState{
+		Transaction: headerTransaction(u.state.Transaction),
+		ChunkIndex:  u.state.ChunkIndex,
+		TxPosted:    u.state.TxPosted,
+	}
+}
+
+func (u *ArweaveUploader) Transaction() *types.Transaction {
+	return u.tx
+}
+
+func (u *ArweaveUploader) TotalChunks() int {
+	if u.tx.Chunks == nil {
+		return 0
+	}
+	return len(u.tx.Chunks.Chunks)
+}
+
+func (u *ArweaveUploader) IsComplete() bool {
+	return u.state.TxPosted && u.state.ChunkIndex >= u.TotalChunks()
+}
+
+// Release the underlying file, temporary files created from a reader are removed
+func (u *ArweaveUploader) Close() error {
+	if u.file == nil {
+		return nil
+	}
+
+	err := u.file.Close()
+	if u.temp {
+		os.Remove(u.file.Name())
+	}
+	u.file = nil
+	return err
+}
+
+func (u *ArweaveUploader) retry(ctx context.Context, fn func() (string, int, error)) error {
+	var lastErr error
+	delay := u.options.RetryDelay
+
+	for attempt := 0; attempt < u.options.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		body, code, err := fn()
+		if err == nil && code >= http.StatusOK && code < http.StatusMultipleChoices {
+			return nil
+		}
+
+		lastErr = fmt.Errorf("status %d, body %s, err %v", code, body, err)
+		if _, ok := types.FATAL_CHUNK_UPLOAD_ERRORS[body]; ok {
+			return lastErr
+		}
+	}
+	return lastErr
+}
+
+func (u *ArweaveUploader) saveState() error {
+	if u.options.StateFile == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(u.state)
+	if err != nil {
+		return fmt.Errorf("json.Marshal err %v", err)
+	}
+
+	if err := os.WriteFile(u.options.StateFile, data, 0644); err != nil {
+		return fmt.Errorf("os.WriteFile err %v", err)
+	}
+	return nil
+}
+
+func (u *ArweaveUploader) reportProgress() {
+	if u.options.OnProgress == nil {
+		return
+	}
+
+	progress := UploadProgress{
+		TxID:           u.tx.ID,
+		UploadedChunks: u.state.ChunkIndex,
+		TotalChunks:    u.TotalChunks(),
+	}
+	if u.tx.Chunks != nil {
+		chunks := u.tx.Chunks.Chunks
+		if len(chunks) > 0 {
+			progress.TotalBytes = int64(chunks[len(chunks)-1].MaxByteRange)
+		}
+		if u.state.ChunkIndex > 0 {
+			progress.UploadedBytes = int64(chunks[u.state.ChunkIndex-1].MaxByteRange)
+		}
+	}
+	u.options.OnProgress(progress)
+}
+
+// Copy the signed transaction fields, leaving out the data and the chunks
+func headerTransaction(tx *types.Transaction) *types.Transaction {
+	return &types.Transaction{
+		Format:    tx.Format,
+		ID:        tx.ID,
+		LastTx:    tx.LastTx,
+		Owner:     tx.Owner,
+		Tags:      tx.Tags,
+		Target:    tx.Target,
+		Quantity:  tx.Quantity,
+		DataSize:  tx.DataSize,
+		DataRoot:  tx.DataRoot,
+		Reward:    tx.Reward,
+		Signature: tx.Signature,
+	}
+}
+
+func spoolTempFile(r io.Reader) (*os.File, error) {
+	file, err := os.CreateTemp("", "gmar-upload-")
+	if err != nil {
+		return nil, fmt.Errorf("os.CreateTemp err %v", err)
+	}
+
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("io.Copy err %v", err)
+	}
+	return file, nil
+}
@@ -0,0 +1,265 @@
+package gmar
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/everFinance/goar"
+	"github.com/everFinance/goar/types"
+	"github.com/everFinance/goar/utils"
+)
+
+// Minimal stand-in for an arweave node, enough for the uploader
+type testArweaveNode struct {
+	mu         sync.Mutex
+	balance    string
+	txs        []*types.Transaction
+	chunks     map[string][]byte
+	chunkCalls int
+	failChunk  func(call int) bool
+	items      map[string]*testArweaveItem
+	weaveSize  int64
+}
+
+type testArweaveItem struct {
+	data   []byte
+	tags   []types.Tag
+	offset int64
+}
+
+// Serve data under id, items larger than 300KiB are only available chunk by chunk
+func (n *testArweaveNode) addItem(id string, data []byte, tags ...types.Tag) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.items == nil {
+		n.items = make(map[string]*testArweaveItem)
+	}
+	n.items[id] = &testArweaveItem{data: data, tags: tags, offset: n.weaveSize}
+	n.weaveSize += int64(len(data))
+}
+
+func newTestArweaveClient(t *testing.T, node *testArweaveNode) *ArweaveClient {
+	t.Helper()
+
+	node.chunks = make(map[string][]byte)
+	if node.balance == "" {
+		node.balance = "1000000000000000"
+	}
+	server := httptest.NewServer(http.HandlerFunc(node.serveHTTP))
+	t.Cleanup(server.Close)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Unable to generate rsa key, msg: %v", err)
+	}
+
+	client := goar.NewClient(server.URL)
+	wallet := &goar.Wallet{Client: client, Signer: goar.NewSignerByPrivateKey(key)}
+	return &ArweaveClient{Node: server.URL, Wallet: wallet, Client: client}
+}
+
+func (n *testArweaveNode) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/tx_anchor":
+		w.Write([]byte("anchor"))
+	case strings.HasPrefix(r.URL.Path, "/price/"):
+		size, _ := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/price/"), 10, 64)
+		w.Write([]byte(strconv.FormatInt(1000+size, 10)))
+	case strings.HasPrefix(r.URL.Path, "/wallet/") && strings.HasSuffix(r.URL.Path, "/balance"):
+		w.Write([]byte(n.balance))
+	case r.URL.Path == "/tx" && r.Method == http.MethodPost:
+		tx := new(types.Transaction)
+		if err := json.NewDecoder(r.Body).Decode(tx); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		n.txs = append(n.txs, tx)
+		w.Write([]byte("OK"))
+	case r.URL.Path == "/chunk" && r.Method == http.MethodPost:
+		n.chunkCalls++
+		if n.failChunk != nil && n.failChunk(n.chunkCalls) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		chunk := new(types.GetChunk)
+		if err := json.NewDecoder(r.Body).Decode(chunk); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := utils.Base64Decode(chunk.Chunk)
+		n.chunks[chunk.Offset] = data
+		w.Write([]byte("OK"))
+	case strings.HasPrefix(r.URL.Path, "/tx/"):
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/tx/"), "/")
+		item, ok := n.items[parts[0]]
+		if !ok || len(parts) != 2 {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch parts[1] {
+		case "data":
+			if len(item.data) > 300*1024 {
+				http.Error(w, "tx_data_too_big", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(utils.Base64Encode(item.data)))
+		case "offset":
+			json.NewEncoder(w).Encode(types.TransactionOffset{
+				Size:   strconv.Itoa(len(item.data)),
+				Offset: strconv.FormatInt(item.offset+int64(len(item.data))-1, 10),
+			})
+		case "tags":
+			json.NewEncoder(w).Encode(utils.TagsEncode(item.tags))
+		default:
+			http.NotFound(w, r)
+		}
+	case strings.HasPrefix(r.URL.Path, "/chunk/"):
+		offset, _ := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/chunk/"), 10, 64)
+		for _, item := range n.items {
+			if offset >= item.offset && offset < item.offset+int64(len(item.data)) {
+				start := offset - item.offset
+				end := min(start+types.MAX_CHUNK_SIZE, int64(len(item.data)))
+				json.NewEncoder(w).Encode(types.TransactionChunk{Chunk: utils.Base64Encode(item.data[start:end])})
+				return
+			}
+		}
+		http.NotFound(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (n *testArweaveNode) receivedBytes() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	size := 0
+	for _, data := range n.chunks {
+		size += len(data)
+	}
+	return size
+}
+
+func randomBytes(t *testing.T, size int) []byte {
+	t.Helper()
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(rand.Reader, data); err != nil {
+		t.Fatalf("Unable to generate random data, msg: %v", err)
+	}
+	return data
+}
+
+func TestUploaderRetriesChunks(t *testing.T) {
+	node := &testArweaveNode{failChunk: func(call int) bool { return call == 2 }}
+	client := newTestArweaveClient(t, node)
+	data := randomBytes(t, 600*1024)
+
+	var progress []UploadProgress
+	uploader, err := client.NewUploader(bytes.NewReader(data), &UploadOptions{
+		RetryDelay: time.Millisecond,
+		OnProgress: func(p UploadProgress) { progress = append(progress, p) },
+	})
+	if err != nil {
+		t.Fatalf("NewUploader err, msg: %v", err)
+	}
+	defer uploader.Close()
+
+	tx, err := uploader.Upload(context.Background())
+	if err != nil {
+		t.Fatalf("Upload err, msg: %v", err)
+	}
+
+	if len(node.txs) != 1 || node.txs[0].ID != tx.ID || node.txs[0].Data != "" {
+		t.Errorf("expected a single transaction header %s without data, but got %v", tx.ID, node.txs)
+	}
+	if got := node.receivedBytes(); got != len(data) {
+		t.Errorf("expected %d bytes in chunks, but got %d", len(data), got)
+	}
+	if uploader.TotalChunks() != 3 || !uploader.IsComplete() {
+		t.Errorf("expected 3 uploaded chunks, but got %d complete %v", uploader.TotalChunks(), uploader.IsComplete())
+	}
+
+	last := progress[len(progress)-1]
+	if last.UploadedChunks != 3 || last.UploadedBytes != int64(len(data)) || last.TotalBytes != int64(len(data)) {
+		t.Errorf("unexpected final progress %+v", last)
+	}
+}
+
+func TestUploaderResume(t *testing.T) {
+	failing := true
+	node := &testArweaveNode{failChunk: func(call int) bool { return failing && call > 1 }}
+	client := newTestArweaveClient(t, node)
+
+	dir := t.TempDir()
+	dataFile := filepath.Join(dir, "data.bin")
+	stateFile := filepath.Join(dir, "data.bin.upload")
+	if err := os.WriteFile(dataFile, randomBytes(t, 600*1024), 0644); err != nil {
+		t.Fatalf("Unable to write data file, msg: %v", err)
+	}
+
+	options := &UploadOptions{MaxRetries: 2, RetryDelay: time.Millisecond, StateFile: stateFile}
+	uploader, err := client.NewFileUploader(dataFile, options)
+	if err != nil {
+		t.Fatalf("NewFileUploader err, msg: %v", err)
+	}
+	if _, err := uploader.Upload(context.Background()); err == nil {
+		t.Fatal("expected an upload error, but got nil")
+	}
+	uploader.Close()
+
+	state, err := LoadUploaderState(stateFile)
+	if err != nil {
+		t.Fatalf("LoadUploaderState err, msg: %v", err)
+	}
+	if !state.TxPosted || state.ChunkIndex != 1 {
+		t.Fatalf("expected posted transaction with 1 chunk, but got %+v", state)
+	}
+
+	failing = false
+	resumed, err := client.ResumeFileUploader(state, dataFile, options)
+	if err != nil {
+		t.Fatalf("ResumeFileUploader err, msg: %v", err)
+	}
+	defer resumed.Close()
+
+	tx, err := resumed.Upload(context.Background())
+	if err != nil {
+		t.Fatalf("Upload err, msg: %v", err)
+	}
+	if tx.ID != state.Transaction.ID || len(node.txs) != 1 {
+		t.Errorf("expected resumed upload of %s without reposting, but got %s", state.Transaction.ID, tx.ID)
+	}
+	if got := node.receivedBytes(); got != 600*1024 {
+		t.Errorf("expected %d bytes in chunks, but got %d", 600*1024, got)
+	}
+	if _, err := os.Stat(stateFile); !os.IsNotExist(err) {
+		t.Errorf("expected state file to be removed, but got %v", err)
+	}
+
+	// Resuming against different data must be refused
+	other := filepath.Join(dir, "other.bin")
+	os.WriteFile(other, randomBytes(t, 600*1024), 0644)
+	if _, err := client.ResumeFileUploader(state, other, options); err == nil {
+		t.Error("expected a data mismatch error, but got nil")
+	}
+}
@@ -1,9 +1,12 @@
 package gmar
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"io"
 	"math/big"
 	"net/http"
+	"os"
 
 	gm "github.com/W3Tools/go-modules"
 	"github.com/everFinance/goar/types"
@@ -29,29 +32,8 @@ func (a *ArweaveClient) GetTransaction(data []byte, manifest bool) (*types.Trans
 		return nil, fmt.Errorf("a.GetTxPrice err %v", err)
 	}
 
-	var tags []types.Tag
-
 	fileHash, _ := gm.ReadFileHash(data)
-
-	if manifest {
-		tags = append(tags, types.Tag{
-			Name:  "Content-Type",
-			Value: "application/x.arweave-manifest+json",
-		})
-	} else {
-		tags = append(tags, types.Tag{
-			Name:  "Content-Type",
-			Value: http.DetectContentType(data),
-		})
-		tags = append(tags, types.Tag{
-			Name:  "User-Agent",
-			Value: "W3Tools",
-		})
-		tags = append(tags, types.Tag{
-			Name:  "FileHash",
-			Value: fileHash,
-		})
-	}
+	tags := newTransactionTags(http.DetectContentType(data), fileHash, manifest)
 
 	tx := &types.Transaction{
 		Format:   2,
@@ -60,7 +42,7 @@ func (a *ArweaveClient) GetTransaction(data []byte, manifest bool) (*types.Trans
 		Tags:     utils.TagsEncode(tags),
 		Data:     utils.Base64Encode(data),
 		DataSize: fmt.Sprintf("%d", len(data)),
-		Reward:   fmt.Sprintf("%d", reward*(100)/100),
+		Reward:   fmt.Sprintf("%d", a.applyRewardMultiplier(reward)),
 		LastTx:   anchor,
 		Owner:    utils.Base64Encode(a.Wallet.Signer.PubKey.N.Bytes()),
 	}
@@ -72,3 +54,78 @@ func (a *ArweaveClient) GetTransaction(data []byte, manifest bool) (*types.Trans
 
 	return tx, nil
 }
+
+// Build a signed transaction whose data is read from the file instead of being held in memory
+func (a *ArweaveClient) GetFileTransaction(file *os.File, manifest bool) (*types.Transaction, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("file.Stat err %v", err)
+	}
+
+	anchor, err := a.Client.GetTransactionAnchor()
+	if err != nil {
+		return nil, fmt.Errorf("client.GetTransactionAnchor err %v", err)
+	}
+
+	reward, err := a.Client.GetTransactionPrice(int(stat.Size()), nil)
+	if err != nil {
+		return nil, fmt.Errorf("client.GetTransactionPrice err %v", err)
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, io.NewSectionReader(file, 0, stat.Size())); err != nil {
+		return nil, fmt.Errorf("io.Copy err %v", err)
+	}
+
+	header := make([]byte, 512)
+	n, err := file.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("file.ReadAt err %v", err)
+	}
+
+	tags := newTransactionTags(http.DetectContentType(header[:n]), fmt.Sprintf("%x", hash.Sum(nil)), manifest)
+
+	tx := &types.Transaction{
+		Format:     2,
+		Target:     "",
+		Quantity:   "0",
+		Tags:       utils.TagsEncode(tags),
+		DataReader: file,
+		DataSize:   fmt.Sprintf("%d", stat.Size()),
+		Reward:     fmt.Sprintf("%d", a.applyRewardMultiplier(reward)),
+		LastTx:     anchor,
+		Owner:      utils.Base64Encode(a.Wallet.Signer.PubKey.N.Bytes()),
+	}
+
+	err = utils.SignTransaction(tx, a.Wallet.Signer.PrvKey)
+	if err != nil {
+		return nil, fmt.Errorf("utils.SignTransaction err %v", err)
+	}
+
+	return tx, nil
+}
+
+func newTransactionTags(contentType, fileHash string, manifest bool) []types.Tag {
+	var tags []types.Tag
+
+	if manifest {
+		tags = append(tags, types.Tag{
+			Name:  "Content-Type",
+			Value: ManifestContentType,
+		})
+	} else {
+		tags = append(tags, types.Tag{
+			Name:  "Content-Type",
+			Value: contentType,
+		})
+		tags = append(tags, types.Tag{
+			Name:  "User-Agent",
+			Value: "W3Tools",
+		})
+		tags = append(tags, types.Tag{
+			Name:  "FileHash",
+			Value: fileHash,
+		})
+	}
+	return tags
+}
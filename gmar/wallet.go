@@ -0,0 +1,127 @@
+package gmar
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"github.com/everFinance/goar"
+	"github.com/everFinance/goar/utils"
+	"github.com/everFinance/gojwk"
+)
+
+const WalletKeyBits = 4096
+
+// Generate a new RSA-4096 wallet, returned as the content of a JWK keyfile
+func GenerateWallet() ([]byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, WalletKeyBits)
+	if err != nil {
+		return nil, fmt.Errorf("rsa.GenerateKey err %v", err)
+	}
+
+	jwk, err := gojwk.PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("gojwk.PrivateKey err %v", err)
+	}
+
+	data, err := gojwk.Marshal(jwk)
+	if err != nil {
+		return nil, fmt.Errorf("gojwk.Marshal err %v", err)
+	}
+	return data, nil
+}
+
+/*
+Generate a new wallet into keyFile and return its address, an existing file is never overwritten
+
+	address, err := gmar.GenerateWalletFile("wallet.json")
+	if err != nil {
+		fmt.Printf("generate wallet err, msg: %v\n", err)
+		return
+	}
+	fmt.Printf("address: %v\n", address)
+*/
+func GenerateWalletFile(keyFile string) (string, error) {
+	key, err := GenerateWallet()
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.OpenFile(keyFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", fmt.Errorf("os.OpenFile err %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(key); err != nil {
+		return "", fmt.Errorf("f.Write err %v", err)
+	}
+
+	return WalletAddress(key)
+}
+
+// Address of the wallet stored in the JWK keyfile content
+func WalletAddress(key []byte) (string, error) {
+	signer, err := goar.NewSigner(key)
+	if err != nil {
+		return "", fmt.Errorf("goar.NewSigner err %v", err)
+	}
+	return signer.Address, nil
+}
+
+// Create a client from an environment variable holding the JWK keyfile content, either raw or base64 encoded
+func InitArweaveClientFromEnv(name, node string) (*ArweaveClient, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return nil, fmt.Errorf("environment variable %s not set", name)
+	}
+
+	key := bytes.TrimSpace([]byte(value))
+	if !bytes.HasPrefix(key, []byte("{")) {
+		decoded, err := base64.StdEncoding.DecodeString(string(key))
+		if err != nil {
+			return nil, fmt.Errorf("environment variable %s is neither a jwk nor base64, err %v", name, err)
+		}
+		key = decoded
+	}
+
+	return InitArweaveClientFromBytes(key, node)
+}
+
+func (a *ArweaveClient) Address() string {
+	return a.Wallet.Signer.Address
+}
+
+// Base64url encoded public key modulus, the owner field of transactions
+func (a *ArweaveClient) Owner() string {
+	return a.Wallet.Signer.Owner()
+}
+
+// Sign an arbitrary message with RSA-PSS over its SHA-256 digest
+func (a *ArweaveClient) SignMessage(msg []byte) ([]byte, error) {
+	signature, err := a.Wallet.Signer.SignMsg(msg)
+	if err != nil {
+		return nil, fmt.Errorf("signer.SignMsg err %v", err)
+	}
+	return signature, nil
+}
+
+func (a *ArweaveClient) VerifyMessage(msg, signature []byte) error {
+	return VerifyMessage(a.Owner(), msg, signature)
+}
+
+// Verify a signature produced by SignMessage against the owner of the signing wallet
+func VerifyMessage(owner string, msg, signature []byte) error {
+	pubKey, err := utils.OwnerToPubKey(owner)
+	if err != nil {
+		return fmt.Errorf("utils.OwnerToPubKey err %v", err)
+	}
+
+	if err := utils.Verify(msg, pubKey, signature); err != nil {
+		return fmt.Errorf("invalid signature, %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package gmar
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWallet(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "wallet.json")
+	address, err := GenerateWalletFile(keyFile)
+	if err != nil {
+		t.Fatalf("GenerateWalletFile err, msg: %v", err)
+	}
+
+	if _, err := GenerateWalletFile(keyFile); err == nil {
+		t.Error("expected an existing wallet file not to be overwritten")
+	}
+
+	client, err := InitArweaveClient(keyFile, "")
+	if err != nil {
+		t.Fatalf("InitArweaveClient err, msg: %v", err)
+	}
+	if client.Address() != address || len(address) != 43 {
+		t.Errorf("expected address %v, but got %v", address, client.Address())
+	}
+	if client.Wallet.Signer.PubKey.N.BitLen() != WalletKeyBits {
+		t.Errorf("expected a %d bits key, but got %d", WalletKeyBits, client.Wallet.Signer.PubKey.N.BitLen())
+	}
+
+	key, _ := os.ReadFile(keyFile)
+	t.Setenv("GMAR_TEST_WALLET", base64.StdEncoding.EncodeToString(key))
+	fromEnv, err := InitArweaveClientFromEnv("GMAR_TEST_WALLET", "")
+	if err != nil || fromEnv.Address() != address {
+		t.Errorf("expected address %v from env, but got err %v", address, err)
+	}
+
+	msg := []byte("Hello, GO Modules!")
+	signature, err := client.SignMessage(msg)
+	if err != nil {
+		t.Fatalf("SignMessage err, msg: %v", err)
+	}
+	if err := VerifyMessage(client.Owner(), msg, signature); err != nil {
+		t.Errorf("VerifyMessage err, msg: %v", err)
+	}
+	if err := client.VerifyMessage([]byte("tampered"), signature); err == nil {
+		t.Error("expected an error, but got nil")
+	}
+}
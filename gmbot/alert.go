@@ -0,0 +1,366 @@
+package gmbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "INFO"
+	case SeverityWarning:
+		return "WARNING"
+	case SeverityError:
+		return "ERROR"
+	case SeverityCritical:
+		return "CRITICAL"
+	default:
+		return fmt.Sprintf("SEVERITY(%d)", int(s))
+	}
+}
+
+func (s Severity) icon() string {
+	switch {
+	case s >= SeverityCritical:
+		return "🔴"
+	case s >= SeverityError:
+		return "🟠"
+	case s >= SeverityWarning:
+		return "🟡"
+	default:
+		return "🔵"
+	}
+}
+
+var (
+	ErrAlertQueueFull         = errors.New("alert queue is full")
+	ErrAlertDispatcherClosed  = errors.New("alert dispatcher is closed")
+	defaultSuppressionWindow  = 5 * time.Minute
+	defaultAlertRatePerSecond = 1.0
+	defaultAlertBurst         = 5
+	defaultAlertQueueSize     = 1024
+	defaultAlertMaxRetries    = 3
+)
+
+type Alert struct {
+	Severity Severity
+	Key      string // deduplication key, Title when empty
+	Title    string
+	Message  string
+	ChatId   int64 // AlertConfig.ChatId when zero
+}
+
+func (a Alert) dedupKey() string {
+	if a.Key != "" {
+		return a.Key
+	}
+	return a.Title
+}
+
+type AlertConfig struct {
+	ChatId            int64         // default chat, TelegramClient.ChatId when zero
+	MinSeverity       Severity      // alerts below are dropped
+	SuppressionWindow time.Duration // repeated keys within the window are aggregated, 5 minutes when zero
+	RatePerSecond     float64       // messages per second and chat, 1 when zero
+	Burst             int           // token bucket size per chat, 5 when zero
+	QueueSize         int           // pending alerts before Send fails with ErrAlertQueueFull, 1024 when zero
+	MaxRetries        int           // attempts per message on 429 responses, 3 when zero
+	Silent            bool          // send every alert without notification sound
+}
+
+type alertState struct {
+	windowStart time.Time
+	suppressed  int
+	last        Alert
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Deliver alerts asynchronously, deduplicating repeated keys and rate limiting per chat
+type AlertDispatcher struct {
+	client *TelegramClient
+	config AlertConfig
+
+	queue   chan Alert
+	closing chan struct{}
+	abort   chan struct{}
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+
+	states  map[string]*alertState
+	buckets map[int64]*tokenBucket
+
+	now   func() time.Time
+	sleep func(time.Duration) bool
+}
+
+/*
+Create a dispatcher delivering alerts through the client
+
+	dispatcher := gmbot.NewAlertDispatcher(client, gmbot.AlertConfig{SuppressionWindow: time.Minute})
+	defer dispatcher.Close(context.Background())
+
+	dispatcher.Send(gmbot.Alert{Severity: gmbot.SeverityError, Key: "sync-job", Title: "sync job failed", Message: err.Error()})
+*/
+func NewAlertDispatcher(client *TelegramClient, config AlertConfig) *AlertDispatcher {
+	if config.ChatId == 0 {
+		config.ChatId = client.ChatId
+	}
+	if config.SuppressionWindow <= 0 {
+		config.SuppressionWindow = defaultSuppressionWindow
+	}
+	if config.RatePerSecond <= 0 {
+		config.RatePerSecond = defaultAlertRatePerSecond
+	}
+	if config.Burst <= 0 {
+		config.Burst = defaultAlertBurst
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultAlertQueueSize
+	}
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = defaultAlertMaxRetries
+	}
+
+	d := &AlertDispatcher{
+		client:  client,
+		config:  config,
+		queue:   make(chan Alert, config.QueueSize),
+		closing: make(chan struct{}),
+		abort:   make(chan struct{}),
+		done:    make(chan struct{}),
+		states:  make(map[string]*alertState),
+		buckets: make(map[int64]*tokenBucket),
+		now:     time.Now,
+	}
+	d.sleep = d.sleepOrAbort
+
+	go d.run()
+	return d
+}
+
+// Queue the alert without blocking the caller
+func (d *AlertDispatcher) Send(alert Alert) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		return ErrAlertDispatcherClosed
+	}
+
+	if alert.Severity < d.config.MinSeverity {
+		return nil
+	}
+
+	select {
+	case d.queue <- alert:
+		return nil
+	default:
+		return ErrAlertQueueFull
+	}
+}
+
+/*
+Stop accepting alerts, deliver the queued ones and the pending aggregations.
+When ctx expires first the remaining alerts are dropped and ctx.Err() is returned.
+*/
+func (d *AlertDispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.closing)
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		select {
+		case <-d.abort:
+		default:
+			close(d.abort)
+		}
+		<-d.done
+		return ctx.Err()
+	}
+}
+
+func (d *AlertDispatcher) run() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(min(d.config.SuppressionWindow/2, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case alert := <-d.queue:
+			d.process(alert)
+		case <-ticker.C:
+			d.sweep(false)
+		case <-d.closing:
+			d.drain()
+			d.sweep(true)
+			return
+		}
+	}
+}
+
+func (d *AlertDispatcher) drain() {
+	for {
+		select {
+		case alert := <-d.queue:
+			d.process(alert)
+		default:
+			return
+		}
+	}
+}
+
+func (d *AlertDispatcher) process(alert Alert) {
+	key := alert.dedupKey()
+	now := d.now()
+
+	state, ok := d.states[key]
+	if ok && now.Sub(state.windowStart) < d.config.SuppressionWindow {
+		state.suppressed++
+		state.last = alert
+		return
+	}
+
+	// The window expired before the sweep reached it, report its aggregation before starting the next one
+	if ok && state.suppressed > 0 {
+		d.deliver(state.last, state.suppressed+1)
+	}
+
+	d.states[key] = &alertState{windowStart: now, last: alert}
+	d.deliver(alert, 1)
+}
+
+// Emit the aggregation of windows that have expired, or of every window when flushing
+func (d *AlertDispatcher) sweep(flush bool) {
+	now := d.now()
+
+	for key, state := range d.states {
+		if !flush && now.Sub(state.windowStart) < d.config.SuppressionWindow {
+			continue
+		}
+
+		delete(d.states, key)
+		if state.suppressed > 0 {
+			d.deliver(state.last, state.suppressed+1)
+		}
+	}
+}
+
+func (d *AlertDispatcher) deliver(alert Alert, occurrences int) {
+	chatId := alert.ChatId
+	if chatId == 0 {
+		chatId = d.config.ChatId
+	}
+
+	text := FormatAlert(alert, occurrences, d.config.SuppressionWindow)
+	options := &MessageOptions{ParseMode: ParseModeHTML, DisableNotification: d.config.Silent || alert.Severity < SeverityError}
+
+	for attempt := 0; attempt < d.config.MaxRetries; attempt++ {
+		if !d.takeToken(chatId) {
+			return
+		}
+
+		_, err := d.client.SendMessage(chatId, text, options)
+		if err == nil {
+			return
+		}
+
+		retryAfter, ok := RetryAfter(err)
+		if !ok {
+			return
+		}
+
+		// Telegram asks the whole chat to back off, drain the bucket so later messages wait too
+		d.buckets[chatId].tokens = 0
+		if !d.sleep(retryAfter) {
+			return
+		}
+	}
+}
+
+func (d *AlertDispatcher) takeToken(chatId int64) bool {
+	bucket, ok := d.buckets[chatId]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(d.config.Burst), last: d.now()}
+		d.buckets[chatId] = bucket
+	}
+
+	for {
+		now := d.now()
+		bucket.tokens = min(float64(d.config.Burst), bucket.tokens+now.Sub(bucket.last).Seconds()*d.config.RatePerSecond)
+		bucket.last = now
+
+		if bucket.tokens >= 1 {
+			bucket.tokens--
+			return true
+		}
+
+		wait := time.Duration((1 - bucket.tokens) / d.config.RatePerSecond * float64(time.Second))
+		if !d.sleep(wait) {
+			return false
+		}
+	}
+}
+
+func (d *AlertDispatcher) sleepOrAbort(duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-d.abort:
+		return false
+	}
+}
+
+// Format the alert as a HTML message, occurrences above one are reported as an aggregation over the window
+func FormatAlert(alert Alert, occurrences int, window time.Duration) string {
+	var builder strings.Builder
+
+	builder.WriteString(fmt.Sprintf("%s <b>%s</b> %s", alert.Severity.icon(), alert.Severity, EscapeHTML(alert.Title)))
+	if alert.Message != "" {
+		builder.WriteString("\n")
+		builder.WriteString(EscapeHTML(alert.Message))
+	}
+	if occurrences > 1 {
+		builder.WriteString(fmt.Sprintf("\n<i>%d occurrences in the last %s</i>", occurrences, window))
+	}
+	return builder.String()
+}
+
+// Delay requested by a 429 Too Many Requests response of the Bot API
+func RetryAfter(err error) (time.Duration, bool) {
+	var apiErr tgbotapi.Error
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return time.Duration(apiErr.RetryAfter) * time.Second, true
+	}
+	return 0, false
+}
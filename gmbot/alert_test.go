@@ -0,0 +1,180 @@
+package gmbot
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+type testClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Sleep(d time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+	return true
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestAlertDispatcher(t *testing.T, server *testBotServer, config AlertConfig) (*AlertDispatcher, *testClock) {
+	t.Helper()
+
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	d := NewAlertDispatcher(newTestTelegramClient(t, server), config)
+	d.now = clock.Now
+	d.sleep = clock.Sleep
+	return d, clock
+}
+
+func TestAlertDeduplication(t *testing.T) {
+	server := &testBotServer{}
+	d, clock := newTestAlertDispatcher(t, server, AlertConfig{SuppressionWindow: time.Hour, MinSeverity: SeverityWarning})
+
+	for i := 0; i < 5; i++ {
+		if err := d.Send(Alert{Severity: SeverityError, Key: "sync", Title: "sync <job> failed"}); err != nil {
+			t.Fatalf("Send err, msg: %v", err)
+		}
+	}
+	d.Send(Alert{Severity: SeverityInfo, Title: "dropped below min severity"})
+	d.Send(Alert{Severity: SeverityCritical, Title: "other", ChatId: 2002})
+	clock.Advance(time.Minute)
+
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatalf("Close err, msg: %v", err)
+	}
+	if err := d.Send(Alert{Title: "late"}); err != ErrAlertDispatcherClosed {
+		t.Errorf("expected ErrAlertDispatcherClosed, but got %v", err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("expected 3 messages, but got %d", len(requests))
+	}
+
+	first := requests[0].Values.Get("text")
+	if !strings.Contains(first, "<b>ERROR</b> sync &lt;job&gt; failed") || strings.Contains(first, "occurrences") {
+		t.Errorf("unexpected first message %q", first)
+	}
+	if requests[1].Values.Get("chat_id") != "2002" {
+		t.Errorf("expected alert to chat 2002, but got %v", requests[1].Values)
+	}
+
+	var aggregated string
+	for _, req := range requests {
+		if strings.Contains(req.Values.Get("text"), "occurrences") {
+			aggregated = req.Values.Get("text")
+		}
+	}
+	if !strings.Contains(aggregated, "5 occurrences in the last 1h0m0s") {
+		t.Errorf("expected an aggregated message, but got %q", aggregated)
+	}
+}
+
+func TestAlertWindowExpiredBeforeSweep(t *testing.T) {
+	server := &testBotServer{}
+	d, clock := newTestAlertDispatcher(t, server, AlertConfig{SuppressionWindow: time.Hour})
+
+	for i := 0; i < 3; i++ {
+		d.Send(Alert{Severity: SeverityError, Key: "sync", Title: "sync failed"})
+	}
+	// Alerts are processed in order, once the barrier is sent the sync alerts are aggregated
+	d.Send(Alert{Severity: SeverityError, Title: "barrier"})
+	for len(server.Requests()) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	clock.Advance(2 * time.Hour)
+	d.Send(Alert{Severity: SeverityError, Key: "sync", Title: "sync failed again"})
+	d.Close(context.Background())
+
+	requests := server.Requests()
+	if len(requests) != 4 {
+		t.Fatalf("expected 4 messages, but got %d", len(requests))
+	}
+	if text := requests[2].Values.Get("text"); !strings.Contains(text, "3 occurrences") {
+		t.Errorf("expected the aggregation of the expired window, but got %q", text)
+	}
+	if text := requests[3].Values.Get("text"); !strings.Contains(text, "sync failed again") || strings.Contains(text, "occurrences") {
+		t.Errorf("expected the first alert of the new window, but got %q", text)
+	}
+}
+
+func TestAlertRateLimit(t *testing.T) {
+	calls := 0
+	server := &testBotServer{reply: func(req testBotRequest) *tgbotapi.APIResponse {
+		calls++
+		if calls == 2 {
+			return &tgbotapi.APIResponse{ErrorCode: 429, Description: "Too Many Requests", Parameters: &tgbotapi.ResponseParameters{RetryAfter: 7}}
+		}
+		return nil
+	}}
+	d, clock := newTestAlertDispatcher(t, server, AlertConfig{RatePerSecond: 2, Burst: 1})
+
+	for _, title := range []string{"a", "b", "c"} {
+		d.Send(Alert{Severity: SeverityError, Title: title})
+	}
+	d.Close(context.Background())
+
+	if len(server.Requests()) != 4 {
+		t.Fatalf("expected 4 requests including the retry, but got %d", len(server.Requests()))
+	}
+
+	// b waits for a token and is rejected with retry_after, the bucket refills meanwhile, then c waits for a token
+	expected := []time.Duration{500 * time.Millisecond, 7 * time.Second, 500 * time.Millisecond}
+	if len(clock.sleeps) != len(expected) {
+		t.Fatalf("expected sleeps %v, but got %v", expected, clock.sleeps)
+	}
+	for i := range expected {
+		if clock.sleeps[i] != expected[i] {
+			t.Errorf("expected sleeps %v, but got %v", expected, clock.sleeps)
+			break
+		}
+	}
+}
+
+func TestAlertQueueFull(t *testing.T) {
+	server := &testBotServer{}
+	d, _ := newTestAlertDispatcher(t, server, AlertConfig{QueueSize: 1, Burst: 1, RatePerSecond: 0.001})
+	d.now = time.Now
+	d.sleep = d.sleepOrAbort
+
+	// The first alert takes the only token, the next one waits for a refill while the queue fills up
+	d.Send(Alert{Title: "first"})
+	for len(server.Requests()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	var full bool
+	for i := 0; i < 100 && !full; i++ {
+		full = d.Send(Alert{Title: strings.Repeat("x", i+1)}) == ErrAlertQueueFull
+	}
+	if !full {
+		t.Error("expected ErrAlertQueueFull")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := d.Close(ctx); err == nil {
+		t.Error("expected a context error when closing with pending alerts")
+	}
+}
@@ -0,0 +1,423 @@
+package gmbot
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/W3Tools/go-modules/gmrouter"
+	"github.com/gin-gonic/gin"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	WebhookSecretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+	DefaultPollTimeout       = 30 * time.Second
+)
+
+// A /command received from a chat
+type Command struct {
+	Name    string   // lower-case name without the slash and bot mention
+	Args    []string // arguments split on whitespace, quoted arguments are kept together
+	RawArgs string   // everything after the command name
+	Message *tgbotapi.Message
+	Bot     *CommandBot
+}
+
+// Answer in the chat of the command, as a reply to it
+func (c *Command) Reply(text string, options *MessageOptions) error {
+	opts := MessageOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.ReplyToMessageID == 0 {
+		opts.ReplyToMessageID = c.Message.MessageID
+	}
+
+	_, err := c.Bot.client.SendMessage(c.Message.Chat.ID, text, &opts)
+	return err
+}
+
+func (c *Command) ChatID() int64 {
+	return c.Message.Chat.ID
+}
+
+// Sender of the command, zero for channel posts
+func (c *Command) UserID() int {
+	if c.Message.From == nil {
+		return 0
+	}
+	return c.Message.From.ID
+}
+
+type CommandHandler func(ctx context.Context, cmd *Command) error
+
+// Wraps a handler, middlewares run in the order they are registered with Use
+type CommandMiddleware func(next CommandHandler) CommandHandler
+
+type commandEntry struct {
+	handler     CommandHandler
+	description string
+}
+
+// Route /commands received by polling or a webhook to registered handlers
+type CommandBot struct {
+	client *TelegramClient
+
+	mu           sync.RWMutex
+	commands     map[string]commandEntry
+	middlewares  []CommandMiddleware
+	allowedChats map[int64]struct{}
+	allowedUsers map[int]struct{}
+	offset       int
+
+	// Called for commands without a handler, ignored when nil
+	NotFound CommandHandler
+	// Called when a handler fails, replies with the error when nil
+	OnError func(cmd *Command, err error)
+}
+
+func NewCommandBot(client *TelegramClient) *CommandBot {
+	return &CommandBot{
+		client:       client,
+		commands:     make(map[string]commandEntry),
+		allowedChats: make(map[int64]struct{}),
+		allowedUsers: make(map[int]struct{}),
+	}
+}
+
+/*
+Register the handler of /name
+
+	bot := gmbot.NewCommandBot(client)
+	bot.AllowChats(client.ChatId)
+	bot.Handle("status", "show service status", func(ctx context.Context, cmd *gmbot.Command) error {
+		return cmd.Reply("all systems operational", nil)
+	})
+
+	go bot.Poll(ctx, gmbot.DefaultPollTimeout)
+*/
+func (b *CommandBot) Handle(name, description string, handler CommandHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.commands[strings.ToLower(strings.TrimPrefix(name, "/"))] = commandEntry{handler: handler, description: description}
+}
+
+func (b *CommandBot) Use(middlewares ...CommandMiddleware) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.middlewares = append(b.middlewares, middlewares...)
+}
+
+// Only accept commands from these chats, every chat is accepted until one is allowed
+func (b *CommandBot) AllowChats(ids ...int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, id := range ids {
+		b.allowedChats[id] = struct{}{}
+	}
+}
+
+// Only accept commands from these users, every user is accepted until one is allowed
+func (b *CommandBot) AllowUsers(ids ...int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, id := range ids {
+		b.allowedUsers[id] = struct{}{}
+	}
+}
+
+// Registered commands and their descriptions, sorted by name, suitable for a /help handler
+func (b *CommandBot) Commands() [][2]string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var commands [][2]string
+	for name, entry := range b.commands {
+		commands = append(commands, [2]string{name, entry.description})
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i][0] < commands[j][0] })
+	return commands
+}
+
+// Route a single update, non command messages and commands from disallowed chats or users are ignored
+func (b *CommandBot) HandleUpdate(ctx context.Context, update tgbotapi.Update) {
+	message := update.Message
+	if message == nil {
+		message = update.ChannelPost
+	}
+	if message == nil || message.Chat == nil {
+		return
+	}
+
+	cmd, ok := b.parseCommand(message)
+	if !ok || !b.allowed(cmd) {
+		return
+	}
+
+	b.mu.RLock()
+	entry, found := b.commands[cmd.Name]
+	middlewares := b.middlewares
+	b.mu.RUnlock()
+
+	handler := entry.handler
+	if !found {
+		if b.NotFound == nil {
+			return
+		}
+		handler = b.NotFound
+	}
+
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	if err := handler(ctx, cmd); err != nil {
+		if b.OnError != nil {
+			b.OnError(cmd, err)
+			return
+		}
+		cmd.Reply(fmt.Sprintf("⚠️ /%s failed: %v", cmd.Name, err), nil)
+	}
+}
+
+/*
+Receive updates by long polling until ctx is done, timeout is the server side wait of every getUpdates call.
+Polling fails while a webhook is set, call DeleteWebhook first.
+*/
+func (b *CommandBot) Poll(ctx context.Context, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = DefaultPollTimeout
+	}
+
+	for {
+		updates, err := b.getUpdates(ctx, timeout)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			delay := time.Second
+			if retryAfter, ok := RetryAfter(err); ok {
+				delay = retryAfter
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+			continue
+		}
+
+		for _, update := range updates {
+			b.HandleUpdate(ctx, update)
+		}
+	}
+}
+
+func (b *CommandBot) getUpdates(ctx context.Context, timeout time.Duration) ([]tgbotapi.Update, error) {
+	b.mu.RLock()
+	offset := b.offset
+	b.mu.RUnlock()
+
+	v := url.Values{}
+	v.Set("offset", strconv.Itoa(offset))
+	v.Set("timeout", strconv.Itoa(int(timeout.Seconds())))
+
+	endpoint := fmt.Sprintf(tgbotapi.APIEndpoint, b.client.BotToken, "getUpdates")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rsp, err := b.client.BotApi.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("getUpdates %v", err)
+	}
+	defer rsp.Body.Close()
+
+	var apiRsp tgbotapi.APIResponse
+	if err := json.NewDecoder(rsp.Body).Decode(&apiRsp); err != nil {
+		return nil, fmt.Errorf("json.Decode getUpdates %v", err)
+	}
+
+	if !apiRsp.Ok {
+		parameters := tgbotapi.ResponseParameters{}
+		if apiRsp.Parameters != nil {
+			parameters = *apiRsp.Parameters
+		}
+		return nil, tgbotapi.Error{Message: apiRsp.Description, ResponseParameters: parameters}
+	}
+
+	var updates []tgbotapi.Update
+	if err := json.Unmarshal(apiRsp.Result, &updates); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal updates %v", err)
+	}
+
+	if len(updates) > 0 {
+		b.mu.Lock()
+		b.offset = updates[len(updates)-1].UpdateID + 1
+		b.mu.Unlock()
+	}
+	return updates, nil
+}
+
+/*
+Receive updates pushed by Telegram, requests without the secret token are rejected.
+secretToken is required, anyone who finds the url could push updates otherwise.
+
+	group := gmrouter.InitRouter("/api", false)
+	group.POST("/telegram/webhook", bot.WebhookHandler(secret))
+	bot.SetWebhook("https://example.com/api/telegram/webhook", secret)
+*/
+func (b *CommandBot) WebhookHandler(secretToken string) gin.HandlerFunc {
+	if secretToken == "" {
+		panic("gmbot: WebhookHandler secretToken is required")
+	}
+
+	return func(ctx *gin.Context) {
+		r := gmrouter.Router{ApiContext: ctx}
+
+		token := r.RequestHeaderGet(WebhookSecretTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secretToken)) != 1 {
+			r.ApiResponseUnauthorized()
+			ctx.Abort()
+			return
+		}
+
+		var update tgbotapi.Update
+		if err := r.BindJSON(&update); err != nil {
+			r.ApiResponseBadRequest()
+			ctx.Abort()
+			return
+		}
+
+		b.HandleUpdate(ctx.Request.Context(), update)
+		r.ApiResponseOk("ok")
+	}
+}
+
+// Register the webhook url, Telegram sends secretToken back in the X-Telegram-Bot-Api-Secret-Token header.
+// secretToken is required like in WebhookHandler, a webhook registered without it would be rejected
+func (b *CommandBot) SetWebhook(webhookURL, secretToken string) error {
+	if secretToken == "" {
+		return errors.New("gmbot: SetWebhook secretToken is required")
+	}
+
+	v := url.Values{}
+	v.Set("url", webhookURL)
+	v.Set("secret_token", secretToken)
+
+	if _, err := b.client.BotApi.MakeRequest("setWebhook", v); err != nil {
+		return fmt.Errorf("tg.BotApi.MakeRequest setWebhook %w", err)
+	}
+	return nil
+}
+
+func (b *CommandBot) DeleteWebhook() error {
+	if _, err := b.client.BotApi.MakeRequest("deleteWebhook", url.Values{}); err != nil {
+		return fmt.Errorf("tg.BotApi.MakeRequest deleteWebhook %w", err)
+	}
+	return nil
+}
+
+func (b *CommandBot) parseCommand(message *tgbotapi.Message) (*Command, bool) {
+	text := strings.TrimSpace(message.Text)
+	if !strings.HasPrefix(text, "/") {
+		return nil, false
+	}
+
+	name, rawArgs := text[1:], ""
+	if i := strings.IndexFunc(name, unicode.IsSpace); i >= 0 {
+		name, rawArgs = name[:i], name[i:]
+	}
+
+	name, mention, mentioned := strings.Cut(name, "@")
+	if mentioned && !strings.EqualFold(mention, b.client.BotApi.Self.UserName) {
+		return nil, false
+	}
+
+	if name == "" {
+		return nil, false
+	}
+
+	rawArgs = strings.TrimSpace(rawArgs)
+	return &Command{
+		Name:    strings.ToLower(name),
+		Args:    ParseCommandArgs(rawArgs),
+		RawArgs: rawArgs,
+		Message: message,
+		Bot:     b,
+	}, true
+}
+
+func (b *CommandBot) allowed(cmd *Command) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if len(b.allowedChats) > 0 {
+		if _, ok := b.allowedChats[cmd.ChatID()]; !ok {
+			return false
+		}
+	}
+	if len(b.allowedUsers) > 0 {
+		if _, ok := b.allowedUsers[cmd.UserID()]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Split command arguments on whitespace, single or double quotes group words
+
+	gmbot.ParseCommandArgs(`restart "sync worker" --force`)
+	Output: ["restart" "sync worker" "--force"]
+*/
+func ParseCommandArgs(s string) []string {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, c := range s {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote = c
+			inArg = true
+		case unicode.IsSpace(c):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(c)
+			inArg = true
+		}
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
@@ -0,0 +1,217 @@
+package gmbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func newTestUpdate(id int, chatID int64, userID int, text string) tgbotapi.Update {
+	return tgbotapi.Update{
+		UpdateID: id,
+		Message: &tgbotapi.Message{
+			MessageID: id * 10,
+			From:      &tgbotapi.User{ID: userID},
+			Chat:      &tgbotapi.Chat{ID: chatID},
+			Text:      text,
+		},
+	}
+}
+
+func TestParseCommandArgs(t *testing.T) {
+	tests := map[string][]string{
+		"":                              nil,
+		"a b  c":                        {"a", "b", "c"},
+		`restart "sync worker" --force`: {"restart", "sync worker", "--force"},
+		`say 'it "works"'`:              {"say", `it "works"`},
+		"x\ty\nz":                       {"x", "y", "z"},
+	}
+
+	for input, expected := range tests {
+		if result := ParseCommandArgs(input); !reflect.DeepEqual(result, expected) {
+			t.Errorf("%q expected %q, but got %q", input, expected, result)
+		}
+	}
+}
+
+func TestCommandRouting(t *testing.T) {
+	server := &testBotServer{}
+	bot := NewCommandBot(newTestTelegramClient(t, server))
+	bot.AllowChats(1001)
+	bot.AllowUsers(7)
+
+	var mu sync.Mutex
+	var calls []string
+	bot.Use(func(next CommandHandler) CommandHandler {
+		return func(ctx context.Context, cmd *Command) error {
+			mu.Lock()
+			calls = append(calls, "middleware:"+cmd.Name)
+			mu.Unlock()
+			return next(ctx, cmd)
+		}
+	})
+	bot.Handle("/Status", "show status", func(ctx context.Context, cmd *Command) error {
+		mu.Lock()
+		calls = append(calls, "status:"+strings.Join(cmd.Args, ","))
+		mu.Unlock()
+		return cmd.Reply("ok", nil)
+	})
+	bot.Handle("fail", "always fails", func(ctx context.Context, cmd *Command) error {
+		return errors.New("boom")
+	})
+
+	ctx := context.Background()
+	bot.HandleUpdate(ctx, newTestUpdate(1, 1001, 7, "/status@test_bot a \"b c\""))
+	bot.HandleUpdate(ctx, newTestUpdate(2, 1001, 7, "/status@other_bot"))
+	bot.HandleUpdate(ctx, newTestUpdate(3, 2002, 7, "/status"))
+	bot.HandleUpdate(ctx, newTestUpdate(4, 1001, 8, "/status"))
+	bot.HandleUpdate(ctx, newTestUpdate(5, 1001, 7, "status"))
+	bot.HandleUpdate(ctx, newTestUpdate(6, 1001, 7, "/unknown"))
+	bot.HandleUpdate(ctx, newTestUpdate(7, 1001, 7, "/fail"))
+
+	expected := []string{"middleware:status", "status:a,b c", "middleware:fail"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected calls %v, but got %v", expected, calls)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 replies, but got %d", len(requests))
+	}
+	if requests[0].Values.Get("text") != "ok" || requests[0].Values.Get("reply_to_message_id") != "10" {
+		t.Errorf("unexpected reply %v", requests[0].Values)
+	}
+	if !strings.Contains(requests[1].Values.Get("text"), "/fail failed: boom") {
+		t.Errorf("unexpected error reply %v", requests[1].Values)
+	}
+
+	if commands := bot.Commands(); len(commands) != 2 || commands[0] != [2]string{"fail", "always fails"} {
+		t.Errorf("unexpected commands %v", commands)
+	}
+}
+
+func TestCommandPolling(t *testing.T) {
+	var polls int
+	var mu sync.Mutex
+	server := &testBotServer{reply: func(req testBotRequest) *tgbotapi.APIResponse {
+		if req.Method != "getUpdates" {
+			return nil
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		polls++
+
+		var updates []tgbotapi.Update
+		switch polls {
+		case 1:
+			updates = []tgbotapi.Update{newTestUpdate(41, 1001, 7, "/ping")}
+		case 2:
+			if req.Values.Get("offset") != "42" {
+				return &tgbotapi.APIResponse{Description: "unexpected offset " + req.Values.Get("offset")}
+			}
+			updates = []tgbotapi.Update{newTestUpdate(42, 1001, 7, "/ping again")}
+		default:
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		data, _ := json.Marshal(updates)
+		return &tgbotapi.APIResponse{Ok: true, Result: data}
+	}}
+	bot := NewCommandBot(newTestTelegramClient(t, server))
+
+	pings := make(chan string, 2)
+	bot.Handle("ping", "", func(ctx context.Context, cmd *Command) error {
+		pings <- cmd.RawArgs
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- bot.Poll(ctx, time.Second) }()
+
+	for _, expected := range []string{"", "again"} {
+		select {
+		case args := <-pings:
+			if args != expected {
+				t.Errorf("expected args %q, but got %q", expected, args)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the ping command")
+		}
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, but got %v", err)
+	}
+}
+
+func TestCommandWebhook(t *testing.T) {
+	server := &testBotServer{}
+	bot := NewCommandBot(newTestTelegramClient(t, server))
+
+	handled := 0
+	bot.Handle("status", "", func(ctx context.Context, cmd *Command) error {
+		handled++
+		return nil
+	})
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/telegram/webhook", bot.WebhookHandler("s3cret"))
+
+	post := func(secret, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/telegram/webhook", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if secret != "" {
+			req.Header.Set(WebhookSecretTokenHeader, secret)
+		}
+		rsp := httptest.NewRecorder()
+		engine.ServeHTTP(rsp, req)
+		return rsp.Code
+	}
+
+	update, _ := json.Marshal(newTestUpdate(1, 1001, 7, "/status"))
+	for _, secret := range []string{"", "wrong"} {
+		if code := post(secret, string(update)); code != http.StatusUnauthorized {
+			t.Errorf("expected 401 for secret %q, but got %d", secret, code)
+		}
+	}
+	if code := post("s3cret", "{"); code != http.StatusBadRequest {
+		t.Errorf("expected 400, but got %d", code)
+	}
+	if code := post("s3cret", string(update)); code != http.StatusOK || handled != 1 {
+		t.Errorf("expected 200 and a handled command, but got %d and %d", code, handled)
+	}
+
+	if err := bot.SetWebhook("https://example.com/telegram/webhook", "s3cret"); err != nil {
+		t.Fatalf("SetWebhook err, msg: %v", err)
+	}
+	requests := server.Requests()
+	last := requests[len(requests)-1]
+	if last.Method != "setWebhook" || last.Values.Get("secret_token") != "s3cret" {
+		t.Errorf("unexpected request %+v", last)
+	}
+
+	if err := bot.SetWebhook("https://example.com/telegram/webhook", ""); err == nil || len(server.Requests()) != len(requests) {
+		t.Errorf("expected SetWebhook to refuse an empty secret token without a request, but got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected WebhookHandler to panic without a secret token")
+		}
+	}()
+	bot.WebhookHandler("")
+}
@@ -0,0 +1,175 @@
+package gmbot
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Send notifications as plain text email
+type SMTPNotifier struct {
+	Host        string
+	Port        int    // 587 when zero, or 465 with ImplicitTLS
+	Username    string // no authentication when empty
+	Password    string
+	From        string
+	To          []string
+	ImplicitTLS bool          // connect with TLS directly instead of upgrading with STARTTLS
+	TLSConfig   *tls.Config   // ServerName is Host when nil
+	Timeout     time.Duration // whole delivery, 10 seconds when zero
+}
+
+/*
+Create a notifier sending through an SMTP server, STARTTLS is used whenever the server offers it
+
+	notifier := gmbot.NewSMTPNotifier("smtp.example.com", 587, "alerts@example.com", password, "alerts@example.com", "ops@example.com")
+*/
+func NewSMTPNotifier(host string, port int, username, password, from string, to ...string) *SMTPNotifier {
+	return &SMTPNotifier{Host: host, Port: port, Username: username, Password: password, From: from, To: to}
+}
+
+func (s *SMTPNotifier) Notify(ctx context.Context, notification Notification) error {
+	if len(s.To) == 0 {
+		return fmt.Errorf("smtp notifier without recipients")
+	}
+
+	message, err := s.message(notification)
+	if err != nil {
+		return err
+	}
+
+	client, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := s.send(client, message); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (s *SMTPNotifier) dial(ctx context.Context) (*smtp.Client, error) {
+	port := s.Port
+	if port == 0 {
+		port = 587
+		if s.ImplicitTLS {
+			port = 465
+		}
+	}
+
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	tlsConfig := s.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{ServerName: s.Host}
+	}
+
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(port))
+	dialer := &net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("net.Dial %s %w", addr, err)
+	}
+	conn.SetDeadline(deadline)
+
+	if s.ImplicitTLS {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("smtp.NewClient %w", err)
+	}
+
+	if ok, _ := client.Extension("STARTTLS"); ok && !s.ImplicitTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("smtp.StartTLS %w", err)
+		}
+	}
+
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("smtp.Auth %w", err)
+		}
+	}
+	return client, nil
+}
+
+func (s *SMTPNotifier) send(client *smtp.Client, message []byte) error {
+	from, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return fmt.Errorf("mail.ParseAddress %s %v", s.From, err)
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("smtp.Mail %w", err)
+	}
+
+	for _, to := range s.To {
+		address, err := mail.ParseAddress(to)
+		if err != nil {
+			return fmt.Errorf("mail.ParseAddress %s %v", to, err)
+		}
+		if err := client.Rcpt(address.Address); err != nil {
+			return fmt.Errorf("smtp.Rcpt %s %w", address.Address, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp.Data %w", err)
+	}
+	if _, err := w.Write(message); err != nil {
+		return fmt.Errorf("smtp.Data write %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp.Data close %w", err)
+	}
+	return nil
+}
+
+func (s *SMTPNotifier) message(notification Notification) ([]byte, error) {
+	for _, value := range append([]string{s.From}, s.To...) {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("invalid email address %q", value)
+		}
+	}
+
+	subject := fmt.Sprintf("[%s] %s", notification.Severity, strings.Join(strings.Fields(notification.Title), " "))
+
+	var buffer bytes.Buffer
+	buffer.WriteString("From: " + s.From + "\r\n")
+	buffer.WriteString("To: " + strings.Join(s.To, ", ") + "\r\n")
+	buffer.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	buffer.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	buffer.WriteString("MIME-Version: 1.0\r\n")
+	buffer.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buffer.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	w := quotedprintable.NewWriter(&buffer)
+	w.Write([]byte(strings.ReplaceAll(strings.ReplaceAll(notification.Message, "\r\n", "\n"), "\n", "\r\n")))
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("quotedprintable.Close %v", err)
+	}
+	return buffer.Bytes(), nil
+}
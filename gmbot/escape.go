@@ -0,0 +1,55 @@
+package gmbot
+
+import "strings"
+
+var (
+	markdownReplacer          = newEscapeReplacer("_*`[")
+	markdownV2Replacer        = newEscapeReplacer("\\_*[]()~`>#+-=|{}.!")
+	markdownV2CodeReplacer    = newEscapeReplacer("\\`")
+	markdownV2LinkURLReplacer = newEscapeReplacer("\\)")
+
+	htmlReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;")
+)
+
+func newEscapeReplacer(chars string) *strings.Replacer {
+	var pairs []string
+	for _, c := range chars {
+		pairs = append(pairs, string(c), "\\"+string(c))
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+/*
+Escape text for the legacy Markdown parse mode
+
+	gmbot.EscapeMarkdown("file_name.go")
+	Output: file\_name.go
+*/
+func EscapeMarkdown(text string) string {
+	return markdownReplacer.Replace(text)
+}
+
+/*
+Escape text for the MarkdownV2 parse mode, every reserved character is prefixed with a backslash
+
+	gmbot.EscapeMarkdownV2("balance: 1.5 (AR)!")
+	Output: balance: 1\.5 \(AR\)\!
+*/
+func EscapeMarkdownV2(text string) string {
+	return markdownV2Replacer.Replace(text)
+}
+
+// Escape text placed inside MarkdownV2 `code` or ```pre``` entities
+func EscapeMarkdownV2Code(text string) string {
+	return markdownV2CodeReplacer.Replace(text)
+}
+
+// Escape the URL part of a MarkdownV2 inline link [text](url)
+func EscapeMarkdownV2URL(text string) string {
+	return markdownV2LinkURLReplacer.Replace(text)
+}
+
+// Escape text for the HTML parse mode
+func EscapeHTML(text string) string {
+	return htmlReplacer.Replace(text)
+}
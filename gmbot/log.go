@@ -0,0 +1,415 @@
+package gmbot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// Length limit of a Telegram text message, counted in UTF-16 code units
+const MaxMessageLength = 4096
+
+var (
+	defaultLogBatchInterval = 2 * time.Second
+	defaultLogQueueSize     = 1024
+	defaultLogMaxRetries    = 3
+)
+
+type LogHandlerOptions struct {
+	ChatId        int64         // TelegramClient.ChatId when zero
+	Level         slog.Leveler  // records below are ignored, slog.LevelError when nil
+	BatchInterval time.Duration // records arriving within the interval are sent together, 2 seconds when zero
+	QueueSize     int           // pending records before new ones are dropped, 1024 when zero
+	MaxRetries    int           // attempts per message on 429 responses, 3 when zero
+	AddSource     bool          // append the file and line of the log call
+	Prefix        string        // first line of every batch, e.g. the service name
+	OnError       func(error)   // called when a message can not be delivered, must not log through this handler
+}
+
+// A slog.Handler forwarding records to a chat, formatting and delivery happen in the background
+type TelegramHandler struct {
+	sink   *logSink
+	attrs  string // preformatted attributes of WithAttrs
+	groups string // group prefix of WithGroup, ending with a dot
+}
+
+type logEntry struct {
+	level slog.Level
+	text  string
+}
+
+// State shared by a handler and every handler derived with WithAttrs or WithGroup
+type logSink struct {
+	client  *TelegramClient
+	options LogHandlerOptions
+
+	queue   chan logEntry
+	closing chan struct{}
+	abort   chan struct{}
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+	dropped atomic.Int64
+}
+
+/*
+Create a handler sending records at or above options.Level to the client, call Close before exiting to flush the pending records
+
+	handler := gmbot.NewTelegramHandler(client, &gmbot.LogHandlerOptions{Level: slog.LevelWarn, Prefix: "api-server"})
+	defer handler.Close(context.Background())
+
+	logger := slog.New(handler)
+	logger.Error("sync job failed", "job", "balances", "err", err)
+*/
+func NewTelegramHandler(client *TelegramClient, options *LogHandlerOptions) *TelegramHandler {
+	opts := LogHandlerOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.ChatId == 0 {
+		opts.ChatId = client.ChatId
+	}
+	if opts.Level == nil {
+		opts.Level = slog.LevelError
+	}
+	if opts.BatchInterval <= 0 {
+		opts.BatchInterval = defaultLogBatchInterval
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultLogQueueSize
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = defaultLogMaxRetries
+	}
+
+	sink := &logSink{
+		client:  client,
+		options: opts,
+		queue:   make(chan logEntry, opts.QueueSize),
+		closing: make(chan struct{}),
+		abort:   make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go sink.run()
+	return &TelegramHandler{sink: sink}
+}
+
+/*
+Create a standard library logger writing to the handler at the level, for code that still uses the log package
+
+	logger := gmbot.NewTelegramLogLogger(handler, slog.LevelError)
+	logger.Printf("payment %s failed: %v", id, err)
+*/
+func NewTelegramLogLogger(handler *TelegramHandler, level slog.Level) *log.Logger {
+	return slog.NewLogLogger(handler, level)
+}
+
+func (h *TelegramHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.sink.options.Level.Level()
+}
+
+// Queue the record, it is dropped instead of blocking when the queue is full or the handler is closed
+func (h *TelegramHandler) Handle(_ context.Context, record slog.Record) error {
+	entry := logEntry{level: record.Level, text: h.format(record)}
+
+	h.sink.mu.RLock()
+	defer h.sink.mu.RUnlock()
+
+	if h.sink.closed {
+		return nil
+	}
+
+	select {
+	case h.sink.queue <- entry:
+	default:
+		h.sink.dropped.Add(1)
+	}
+	return nil
+}
+
+func (h *TelegramHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	var builder strings.Builder
+	builder.WriteString(h.attrs)
+	for _, attr := range attrs {
+		writeLogAttr(&builder, h.groups, attr)
+	}
+	return &TelegramHandler{sink: h.sink, attrs: builder.String(), groups: h.groups}
+}
+
+func (h *TelegramHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &TelegramHandler{sink: h.sink, attrs: h.attrs, groups: h.groups + name + "."}
+}
+
+/*
+Stop accepting records and send the pending ones.
+When ctx expires first the remaining records are dropped and ctx.Err() is returned.
+*/
+func (h *TelegramHandler) Close(ctx context.Context) error {
+	s := h.sink
+
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.closing)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		select {
+		case <-s.abort:
+		default:
+			close(s.abort)
+		}
+		<-s.done
+		return ctx.Err()
+	}
+}
+
+func (h *TelegramHandler) format(record slog.Record) string {
+	var builder strings.Builder
+
+	builder.WriteString(fmt.Sprintf("%s %s %s %s", logSeverity(record.Level).icon(), record.Level, record.Time.Format(time.DateTime), record.Message))
+	builder.WriteString(h.attrs)
+	record.Attrs(func(attr slog.Attr) bool {
+		writeLogAttr(&builder, h.groups, attr)
+		return true
+	})
+
+	if h.sink.options.AddSource && record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		builder.WriteString(fmt.Sprintf("\nsource=%s:%d", frame.File, frame.Line))
+	}
+	return builder.String()
+}
+
+func writeLogAttr(builder *strings.Builder, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		// Attributes of a group without a key are inlined
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, child := range attr.Value.Group() {
+			writeLogAttr(builder, prefix, child)
+		}
+		return
+	}
+
+	builder.WriteString(fmt.Sprintf("\n%s%s=%s", prefix, attr.Key, attr.Value))
+}
+
+func logSeverity(level slog.Level) Severity {
+	switch {
+	case level >= slog.LevelError:
+		return SeverityError
+	case level >= slog.LevelWarn:
+		return SeverityWarning
+	default:
+		return SeverityInfo
+	}
+}
+
+func (s *logSink) run() {
+	defer close(s.done)
+
+	var pending []logEntry
+	var timer <-chan time.Time
+
+	for {
+		select {
+		case entry := <-s.queue:
+			pending = append(pending, entry)
+			if timer == nil {
+				timer = time.After(s.options.BatchInterval)
+			}
+		case <-timer:
+			s.flush(pending)
+			pending, timer = nil, nil
+		case <-s.closing:
+			s.flush(s.drain(pending))
+			return
+		}
+	}
+}
+
+func (s *logSink) drain(pending []logEntry) []logEntry {
+	for {
+		select {
+		case entry := <-s.queue:
+			pending = append(pending, entry)
+		default:
+			return pending
+		}
+	}
+}
+
+// Send a batch, records are packed into as few messages as the length limit allows
+func (s *logSink) flush(entries []logEntry) {
+	dropped := s.dropped.Swap(0)
+	if len(entries) == 0 && dropped == 0 {
+		return
+	}
+
+	texts := make([]string, 0, len(entries)+2)
+	if s.options.Prefix != "" {
+		texts = append(texts, s.options.Prefix)
+	}
+	if dropped > 0 {
+		texts = append(texts, fmt.Sprintf("⚠️ %d log records dropped, the queue was full", dropped))
+	}
+
+	silent := true
+	for _, entry := range entries {
+		texts = append(texts, entry.text)
+		if entry.level >= slog.LevelError {
+			silent = false
+		}
+	}
+
+	options := &MessageOptions{DisableNotification: silent, DisableWebPagePreview: true}
+	for _, message := range packMessages(texts, "\n\n", MaxMessageLength) {
+		if !s.send(message, options) {
+			return
+		}
+	}
+}
+
+func (s *logSink) send(text string, options *MessageOptions) bool {
+	for attempt := 0; attempt < s.options.MaxRetries; attempt++ {
+		_, err := s.client.SendMessage(s.options.ChatId, text, options)
+		if err == nil {
+			return true
+		}
+
+		retryAfter, ok := RetryAfter(err)
+		if !ok || attempt == s.options.MaxRetries-1 {
+			if s.options.OnError != nil {
+				s.options.OnError(err)
+			}
+			return true
+		}
+
+		timer := time.NewTimer(retryAfter)
+		select {
+		case <-timer.C:
+		case <-s.abort:
+			timer.Stop()
+			return false
+		}
+	}
+	return true
+}
+
+// Join texts with sep into messages of at most limit UTF-16 code units, texts longer than the limit are split
+func packMessages(texts []string, sep string, limit int) []string {
+	var messages []string
+	var current strings.Builder
+	currentLen := 0
+	sepLen := messageLength(sep)
+
+	for _, text := range texts {
+		for _, part := range SplitMessage(text, limit) {
+			partLen := messageLength(part)
+			if currentLen > 0 && currentLen+sepLen+partLen > limit {
+				messages = append(messages, current.String())
+				current.Reset()
+				currentLen = 0
+			}
+			if currentLen > 0 {
+				current.WriteString(sep)
+				currentLen += sepLen
+			}
+			current.WriteString(part)
+			currentLen += partLen
+		}
+	}
+
+	if currentLen > 0 {
+		messages = append(messages, current.String())
+	}
+	return messages
+}
+
+/*
+Split text into parts of at most limit UTF-16 code units, the unit Telegram counts message length in.
+Parts end at the last line break within the limit when there is one.
+
+	parts := gmbot.SplitMessage(report, gmbot.MaxMessageLength)
+*/
+func SplitMessage(text string, limit int) []string {
+	if limit <= 0 {
+		limit = MaxMessageLength
+	}
+
+	var parts []string
+	for messageLength(text) > limit {
+		// Byte offset where the limit is reached, and of the last line break before it
+		cut, lastBreak, length := 0, -1, 0
+		for i, r := range text {
+			length += runeLength(r)
+			if length > limit {
+				cut = i
+				break
+			}
+			if r == '\n' {
+				lastBreak = i
+			}
+		}
+
+		// A character longer than limit goes alone in its part, the text always advances
+		if cut == 0 {
+			_, cut = utf8.DecodeRuneInString(text)
+		}
+
+		if lastBreak > 0 {
+			parts = append(parts, text[:lastBreak])
+			text = text[lastBreak+1:]
+		} else {
+			parts = append(parts, text[:cut])
+			text = text[cut:]
+		}
+	}
+
+	if text != "" {
+		parts = append(parts, text)
+	}
+	return parts
+}
+
+func messageLength(text string) int {
+	length := 0
+	for _, r := range text {
+		length += runeLength(r)
+	}
+	return length
+}
+
+// Characters outside the basic multilingual plane take a surrogate pair
+func runeLength(r rune) int {
+	if r >= 0x10000 && r <= utf8.MaxRune {
+		return 2
+	}
+	return 1
+}
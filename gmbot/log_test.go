@@ -0,0 +1,148 @@
+package gmbot
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		text     string
+		limit    int
+		expected []string
+	}{
+		{"short", 10, []string{"short"}},
+		{"abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+		{"ab\ncdef\ngh", 8, []string{"ab\ncdef", "gh"}},
+		{"😀😀😀", 4, []string{"😀😀", "😀"}},
+		{"😀a😀", 1, []string{"😀", "a", "😀"}},
+		{"", 4, nil},
+	}
+
+	for _, tt := range tests {
+		if result := SplitMessage(tt.text, tt.limit); !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%q expected %q, but got %q", tt.text, tt.expected, result)
+		}
+	}
+}
+
+func TestTelegramHandler(t *testing.T) {
+	server := &testBotServer{}
+	handler := NewTelegramHandler(newTestTelegramClient(t, server), &LogHandlerOptions{
+		Level:         slog.LevelWarn,
+		BatchInterval: time.Hour,
+		Prefix:        "api-server",
+	})
+
+	logger := slog.New(handler).With("env", "prod").WithGroup("req")
+	logger.Info("ignored")
+	logger.Warn("slow request", "path", "/v1/users", slog.Group("db", "ms", 1200))
+	logger.Error("request failed", "err", "timeout")
+
+	stdLogger := NewTelegramLogLogger(handler, slog.LevelError)
+	stdLogger.Printf("payment %d failed", 42)
+
+	// The batch interval never expires, Close sends everything queued as one message
+	if err := handler.Close(context.Background()); err != nil {
+		t.Fatalf("Close err, msg: %v", err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 message, but got %d", len(requests))
+	}
+
+	text := requests[0].Values.Get("text")
+	for _, expected := range []string{
+		"api-server\n\n",
+		"WARN",
+		"slow request\nenv=prod\nreq.path=/v1/users\nreq.db.ms=1200",
+		"request failed\nenv=prod\nreq.err=timeout",
+		"payment 42 failed",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("expected %q in message %q", expected, text)
+		}
+	}
+	if strings.Contains(text, "ignored") {
+		t.Errorf("unexpected info record in message %q", text)
+	}
+	if requests[0].Values.Get("disable_notification") != "" {
+		t.Error("expected a notification for a batch with errors")
+	}
+
+	// Records after Close are dropped without blocking
+	logger.Error("after close")
+	if len(server.Requests()) != 1 {
+		t.Error("expected no message after Close")
+	}
+}
+
+func TestTelegramHandlerSplit(t *testing.T) {
+	server := &testBotServer{}
+	handler := NewTelegramHandler(newTestTelegramClient(t, server), &LogHandlerOptions{BatchInterval: 10 * time.Millisecond})
+	logger := slog.New(handler)
+
+	logger.Error(strings.Repeat("x", 3000))
+	logger.Error(strings.Repeat("y", 5000))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(server.Requests()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	handler.Close(context.Background())
+
+	requests := server.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("expected 3 messages, but got %d", len(requests))
+	}
+	total := 0
+	for _, req := range requests {
+		text := req.Values.Get("text")
+		if messageLength(text) > MaxMessageLength {
+			t.Errorf("message of %d characters exceeds the limit", messageLength(text))
+		}
+		total += strings.Count(text, "x") + strings.Count(text, "y")
+	}
+	if total != 8000 {
+		t.Errorf("expected 8000 characters of records, but got %d", total)
+	}
+}
+
+func TestTelegramHandlerQueueFull(t *testing.T) {
+	received, release := make(chan struct{}), make(chan struct{})
+	server := &testBotServer{}
+	server.reply = func(req testBotRequest) *tgbotapi.APIResponse {
+		if len(server.Requests()) == 1 {
+			close(received)
+			<-release
+		}
+		return nil
+	}
+	handler := NewTelegramHandler(newTestTelegramClient(t, server), &LogHandlerOptions{BatchInterval: time.Millisecond, QueueSize: 1})
+	logger := slog.New(handler)
+
+	// The first batch blocks the background goroutine, one record fits the queue and the rest are dropped
+	logger.Error("first")
+	<-received
+	for i := 0; i < 4; i++ {
+		logger.Error("burst")
+	}
+	close(release)
+	handler.Close(context.Background())
+
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 messages, but got %d", len(requests))
+	}
+	text := requests[1].Values.Get("text")
+	if strings.Count(text, "burst") != 1 || !strings.Contains(text, "3 log records dropped") {
+		t.Errorf("unexpected message %q", text)
+	}
+}
@@ -0,0 +1,175 @@
+package gmbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	ParseModeMarkdown   = tgbotapi.ModeMarkdown
+	ParseModeMarkdownV2 = "MarkdownV2"
+	ParseModeHTML       = tgbotapi.ModeHTML
+)
+
+type MessageOptions struct {
+	ParseMode             string
+	DisableNotification   bool        // deliver silently
+	DisableWebPagePreview bool        // text messages only
+	ReplyToMessageID      int         // reply in the thread of this message
+	MessageThreadID       int         // forum topic of supergroups
+	ReplyMarkup           interface{} // tgbotapi.InlineKeyboardMarkup, tgbotapi.ReplyKeyboardMarkup, ...
+}
+
+func (o *MessageOptions) apply(v url.Values) error {
+	if o == nil {
+		return nil
+	}
+
+	if o.ParseMode != "" {
+		v.Set("parse_mode", o.ParseMode)
+	}
+	if o.DisableNotification {
+		v.Set("disable_notification", "true")
+	}
+	if o.DisableWebPagePreview {
+		v.Set("disable_web_page_preview", "true")
+	}
+	if o.ReplyToMessageID != 0 {
+		v.Set("reply_to_message_id", strconv.Itoa(o.ReplyToMessageID))
+	}
+	if o.MessageThreadID != 0 {
+		v.Set("message_thread_id", strconv.Itoa(o.MessageThreadID))
+	}
+	if o.ReplyMarkup != nil {
+		data, err := json.Marshal(o.ReplyMarkup)
+		if err != nil {
+			return fmt.Errorf("json.Marshal reply markup %v", err)
+		}
+		v.Set("reply_markup", string(data))
+	}
+	return nil
+}
+
+// Photo or document content, either read from a local path or sent from memory
+type InputFile struct {
+	Name  string
+	Bytes []byte
+	Path  string
+}
+
+func NewInputFileBytes(name string, data []byte) InputFile {
+	return InputFile{Name: name, Bytes: data}
+}
+
+func NewInputFilePath(path string) InputFile {
+	return InputFile{Name: filepath.Base(path), Path: path}
+}
+
+func (f InputFile) upload() (interface{}, error) {
+	switch {
+	case f.Bytes != nil:
+		return tgbotapi.FileBytes{Name: f.Name, Bytes: f.Bytes}, nil
+	case f.Path != "":
+		return f.Path, nil
+	default:
+		return nil, fmt.Errorf("empty input file %s", f.Name)
+	}
+}
+
+// Send a text message to any chat
+func (tg *TelegramClient) SendMessage(chatId int64, text string, options *MessageOptions) (*tgbotapi.Message, error) {
+	v := url.Values{}
+	v.Set("chat_id", strconv.FormatInt(chatId, 10))
+	v.Set("text", text)
+	if err := options.apply(v); err != nil {
+		return nil, err
+	}
+
+	return tg.messageRequest("sendMessage", v)
+}
+
+// Send a photo with an optional caption, the caption follows options.ParseMode
+func (tg *TelegramClient) SendPhoto(chatId int64, photo InputFile, caption string, options *MessageOptions) (*tgbotapi.Message, error) {
+	return tg.sendFile("sendPhoto", "photo", chatId, photo, caption, options)
+}
+
+// Send a document with an optional caption, the caption follows options.ParseMode
+func (tg *TelegramClient) SendDocument(chatId int64, document InputFile, caption string, options *MessageOptions) (*tgbotapi.Message, error) {
+	return tg.sendFile("sendDocument", "document", chatId, document, caption, options)
+}
+
+// Replace the text of a message previously sent by the bot
+func (tg *TelegramClient) EditMessageText(chatId int64, messageId int, text string, options *MessageOptions) (*tgbotapi.Message, error) {
+	v := url.Values{}
+	v.Set("chat_id", strconv.FormatInt(chatId, 10))
+	v.Set("message_id", strconv.Itoa(messageId))
+	v.Set("text", text)
+	if err := options.apply(v); err != nil {
+		return nil, err
+	}
+
+	return tg.messageRequest("editMessageText", v)
+}
+
+func (tg *TelegramClient) DeleteMessage(chatId int64, messageId int) error {
+	v := url.Values{}
+	v.Set("chat_id", strconv.FormatInt(chatId, 10))
+	v.Set("message_id", strconv.Itoa(messageId))
+
+	_, err := tg.BotApi.MakeRequest("deleteMessage", v)
+	if err != nil {
+		return fmt.Errorf("tg.BotApi.MakeRequest deleteMessage %w", err)
+	}
+	return nil
+}
+
+func (tg *TelegramClient) sendFile(method, field string, chatId int64, file InputFile, caption string, options *MessageOptions) (*tgbotapi.Message, error) {
+	upload, err := file.upload()
+	if err != nil {
+		return nil, err
+	}
+
+	v := url.Values{}
+	v.Set("chat_id", strconv.FormatInt(chatId, 10))
+	if caption != "" {
+		v.Set("caption", caption)
+	}
+	if err := options.apply(v); err != nil {
+		return nil, err
+	}
+	v.Del("disable_web_page_preview")
+
+	params := make(map[string]string, len(v))
+	for key := range v {
+		params[key] = v.Get(key)
+	}
+
+	rsp, err := tg.BotApi.UploadFile(method, params, field, upload)
+	if err != nil {
+		return nil, fmt.Errorf("tg.BotApi.UploadFile %s %w", method, err)
+	}
+
+	message := new(tgbotapi.Message)
+	if err := json.Unmarshal(rsp.Result, message); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal %s result %v", method, err)
+	}
+	return message, nil
+}
+
+func (tg *TelegramClient) messageRequest(method string, v url.Values) (*tgbotapi.Message, error) {
+	rsp, err := tg.BotApi.MakeRequest(method, v)
+	if err != nil {
+		return nil, fmt.Errorf("tg.BotApi.MakeRequest %s %w", method, err)
+	}
+
+	message := new(tgbotapi.Message)
+	if err := json.Unmarshal(rsp.Result, message); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal %s result %v", method, err)
+	}
+	return message, nil
+}
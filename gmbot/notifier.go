@@ -0,0 +1,118 @@
+package gmbot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A message to deliver through any notification channel
+type Notification struct {
+	Severity Severity
+	Title    string
+	Message  string
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// Send the notification to ChatId as a HTML message
+func (tg *TelegramClient) Notify(ctx context.Context, notification Notification) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	alert := Alert{Severity: notification.Severity, Title: notification.Title, Message: notification.Message}
+	options := &MessageOptions{ParseMode: ParseModeHTML, DisableNotification: notification.Severity < SeverityError}
+
+	_, err := tg.SendMessage(tg.ChatId, FormatAlert(alert, 1, 0), options)
+	return err
+}
+
+type namedNotifier struct {
+	name     string
+	notifier Notifier
+}
+
+// Send every notification to several channels at once
+type MultiNotifier struct {
+	channels []namedNotifier
+}
+
+/*
+Create a fan-out notifier, channels are added with Add
+
+	notifier := gmbot.NewMultiNotifier().
+		Add("telegram", telegramClient).
+		Add("slack", gmbot.NewSlackNotifier(slackWebhookURL))
+
+	err := notifier.Notify(ctx, gmbot.Notification{Severity: gmbot.SeverityError, Title: "sync job failed", Message: err.Error()})
+	var notifyErr *gmbot.MultiNotifyError
+	if errors.As(err, &notifyErr) {
+		fmt.Println(notifyErr.Errors["slack"])
+	}
+*/
+func NewMultiNotifier() *MultiNotifier {
+	return &MultiNotifier{}
+}
+
+func (m *MultiNotifier) Add(name string, notifier Notifier) *MultiNotifier {
+	m.channels = append(m.channels, namedNotifier{name: name, notifier: notifier})
+	return m
+}
+
+// Send to every channel concurrently, failures are returned as a *MultiNotifyError keyed by channel name
+func (m *MultiNotifier) Notify(ctx context.Context, notification Notification) error {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make(map[string]error)
+
+	for _, channel := range m.channels {
+		wg.Add(1)
+		go func(channel namedNotifier) {
+			defer wg.Done()
+
+			if err := channel.notifier.Notify(ctx, notification); err != nil {
+				mu.Lock()
+				errs[channel.name] = err
+				mu.Unlock()
+			}
+		}(channel)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return &MultiNotifyError{Errors: errs, Channels: len(m.channels)}
+	}
+	return nil
+}
+
+type MultiNotifyError struct {
+	Errors   map[string]error // failure of every channel that failed, keyed by channel name
+	Channels int              // number of channels notified
+}
+
+func (e *MultiNotifyError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	messages := make([]string, 0, len(names))
+	for _, name := range names {
+		messages = append(messages, fmt.Sprintf("%s: %v", name, e.Errors[name]))
+	}
+	return fmt.Sprintf("%d of %d notification channels failed: %s", len(e.Errors), e.Channels, strings.Join(messages, "; "))
+}
+
+func (e *MultiNotifyError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
@@ -0,0 +1,245 @@
+package gmbot
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testWebhookRequest struct {
+	Header http.Header
+	Body   []byte
+}
+
+func newTestWebhookServer(t *testing.T, status int, requests chan<- testWebhookRequest) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- testWebhookRequest{Header: r.Header, Body: body}
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "3")
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWebhookNotifiers(t *testing.T) {
+	requests := make(chan testWebhookRequest, 1)
+	server := newTestWebhookServer(t, http.StatusNoContent, requests)
+	ctx := context.Background()
+	notification := Notification{Severity: SeverityError, Title: "disk <full>", Message: "node-1 at 98%"}
+
+	if err := NewSlackNotifier(server.URL).Notify(ctx, notification); err != nil {
+		t.Fatalf("SlackNotifier.Notify err, msg: %v", err)
+	}
+	var slack struct {
+		Text        string
+		Attachments []struct{ Color, Title, Text string }
+	}
+	json.Unmarshal((<-requests).Body, &slack)
+	if !strings.Contains(slack.Text, "ERROR: disk &lt;full&gt;") || slack.Attachments[0].Color != "#F57C00" ||
+		slack.Attachments[0].Text != "node-1 at 98%" {
+		t.Errorf("unexpected slack payload %+v", slack)
+	}
+
+	discord := NewDiscordNotifier(server.URL)
+	discord.Username = "alerts"
+	if err := discord.Notify(ctx, Notification{Severity: SeverityWarning, Title: "t", Message: strings.Repeat("m", 5000)}); err != nil {
+		t.Fatalf("DiscordNotifier.Notify err, msg: %v", err)
+	}
+	var payload struct {
+		Username string
+		Embeds   []struct {
+			Title       string
+			Description string
+			Color       int
+		}
+	}
+	json.Unmarshal((<-requests).Body, &payload)
+	if payload.Username != "alerts" || payload.Embeds[0].Color != 0xFBC02D || len([]rune(payload.Embeds[0].Description)) != 4096 {
+		t.Errorf("unexpected discord payload %+v", payload.Embeds[0].Title)
+	}
+
+	webhook := NewWebhookNotifier(server.URL, "s3cret")
+	webhook.Header = http.Header{"Authorization": {"Bearer token"}}
+	if err := webhook.Notify(ctx, notification); err != nil {
+		t.Fatalf("WebhookNotifier.Notify err, msg: %v", err)
+	}
+	req := <-requests
+	timestamp, signature := req.Header.Get(WebhookTimestampHeader), req.Header.Get(WebhookSignatureHeader)
+	if err := VerifyWebhookSignature("s3cret", timestamp, req.Body, signature, time.Minute); err != nil {
+		t.Errorf("VerifyWebhookSignature err, msg: %v", err)
+	}
+	if err := VerifyWebhookSignature("other", timestamp, req.Body, signature, time.Minute); err == nil {
+		t.Error("expected an invalid signature error, but got nil")
+	}
+	if req.Header.Get("Authorization") != "Bearer token" {
+		t.Errorf("unexpected headers %v", req.Header)
+	}
+	var generic WebhookPayload
+	json.Unmarshal(req.Body, &generic)
+	if generic.Severity != "ERROR" || generic.Title != "disk <full>" {
+		t.Errorf("unexpected payload %+v", generic)
+	}
+}
+
+func TestWebhookError(t *testing.T) {
+	requests := make(chan testWebhookRequest, 1)
+	server := newTestWebhookServer(t, http.StatusTooManyRequests, requests)
+
+	err := NewSlackNotifier(server.URL).Notify(context.Background(), Notification{Title: "t"})
+	var webhookErr *WebhookError
+	if !errors.As(err, &webhookErr) || webhookErr.StatusCode != http.StatusTooManyRequests || webhookErr.RetryAfter != 3*time.Second {
+		t.Errorf("expected a 429 WebhookError, but got %v", err)
+	}
+}
+
+// Minimal SMTP server accepting a single message without TLS or authentication
+func newTestSMTPServer(t *testing.T) (string, <-chan string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen err, msg: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		write := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		write("220 localhost ESMTP")
+
+		var transcript strings.Builder
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"):
+				write("250-localhost")
+				write("250 8BITMIME")
+			case strings.HasPrefix(command, "MAIL"), strings.HasPrefix(command, "RCPT"):
+				transcript.WriteString(strings.TrimSpace(line) + "\n")
+				write("250 OK")
+			case command == "DATA":
+				write("354 go ahead")
+				for {
+					data, err := reader.ReadString('\n')
+					if err != nil || data == ".\r\n" {
+						break
+					}
+					transcript.WriteString(data)
+				}
+				write("250 OK")
+			case command == "QUIT":
+				write("221 bye")
+				messages <- transcript.String()
+				return
+			default:
+				write("250 OK")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), messages
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, messages := newTestSMTPServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+	portNumber, _ := strconv.Atoi(port)
+
+	notifier := NewSMTPNotifier(host, portNumber, "", "", "Alerts <alerts@example.com>", "ops@example.com", "dev@example.com")
+	err := notifier.Notify(context.Background(), Notification{Severity: SeverityCritical, Title: "db down", Message: "primary unreachable\nfailover started"})
+	if err != nil {
+		t.Fatalf("SMTPNotifier.Notify err, msg: %v", err)
+	}
+
+	transcript := <-messages
+	for _, expected := range []string{
+		"MAIL FROM:<alerts@example.com>",
+		"RCPT TO:<ops@example.com>",
+		"RCPT TO:<dev@example.com>",
+		"To: ops@example.com, dev@example.com\r\n",
+		"Subject: [CRITICAL] db down\r\n",
+	} {
+		if !strings.Contains(transcript, expected) {
+			t.Errorf("expected %q in %q", expected, transcript)
+		}
+	}
+
+	_, body, _ := strings.Cut(transcript, "\r\n\r\n")
+	decoded, _ := io.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+	if strings.TrimSpace(string(decoded)) != "primary unreachable\r\nfailover started" {
+		t.Errorf("unexpected body %q", decoded)
+	}
+
+	if err := NewSMTPNotifier(host, portNumber, "", "", "a@example.com\r\nBcc: x@example.com", "b@example.com").Notify(context.Background(), Notification{}); err == nil {
+		t.Error("expected a header injection error, but got nil")
+	}
+}
+
+type testNotifier struct {
+	mu            sync.Mutex
+	notifications []Notification
+	err           error
+}
+
+func (n *testNotifier) Notify(ctx context.Context, notification Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.notifications = append(n.notifications, notification)
+	return n.err
+}
+
+func TestMultiNotifier(t *testing.T) {
+	server := &testBotServer{}
+	telegram := newTestTelegramClient(t, server)
+	ok, failing := &testNotifier{}, &testNotifier{err: io.ErrUnexpectedEOF}
+
+	var notifier Notifier = NewMultiNotifier().Add("telegram", telegram).Add("ok", ok).Add("failing", failing)
+	err := notifier.Notify(context.Background(), Notification{Severity: SeverityError, Title: "sync failed"})
+
+	var notifyErr *MultiNotifyError
+	if !errors.As(err, &notifyErr) || len(notifyErr.Errors) != 1 || notifyErr.Errors["failing"] == nil {
+		t.Fatalf("expected only the failing channel to fail, but got %v", err)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected the channel error to be wrapped, but got %v", err)
+	}
+	if err.Error() != "1 of 3 notification channels failed: failing: unexpected EOF" {
+		t.Errorf("unexpected error message %q", err.Error())
+	}
+
+	if len(ok.notifications) != 1 || len(failing.notifications) != 1 {
+		t.Errorf("expected every channel to be notified")
+	}
+	requests := server.Requests()
+	if len(requests) != 1 || !strings.Contains(requests[0].Values.Get("text"), "<b>ERROR</b> sync failed") {
+		t.Errorf("unexpected telegram requests %+v", requests)
+	}
+}
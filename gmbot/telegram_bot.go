@@ -2,6 +2,9 @@ package gmbot
 
 import (
 	"fmt"
+	"net/http"
+	"net/url"
+	"path"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 )
@@ -21,6 +24,22 @@ func InitTelegramClient(botToken string, chatId int64) (*TelegramClient, error)
 	return &TelegramClient{BotToken: botToken, ChatId: chatId, BotApi: botApi}, nil
 }
 
+// Create a client talking to a Bot API server other than api.telegram.org, such as a local Bot API server or a test stand-in
+func InitTelegramClientWithEndpoint(botToken string, chatId int64, endpoint string) (*TelegramClient, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("url.Parse %v", err)
+	}
+
+	client := &http.Client{Transport: &endpointTransport{endpoint: u, base: http.DefaultTransport}}
+	botApi, err := tgbotapi.NewBotAPIWithClient(botToken, client)
+	if err != nil {
+		return nil, fmt.Errorf("tgbotapi.NewBotAPIWithClient %v", err)
+	}
+
+	return &TelegramClient{BotToken: botToken, ChatId: chatId, BotApi: botApi}, nil
+}
+
 func (tg *TelegramClient) SendMarkdownMessage(message string) error {
 	msg := tgbotapi.NewMessage(tg.ChatId, message)
 	msg.ParseMode = tgbotapi.ModeMarkdown
@@ -31,3 +50,31 @@ func (tg *TelegramClient) SendMarkdownMessage(message string) error {
 	}
 	return nil
 }
+
+// Send a MarkdownV2 message to ChatId, escape untrusted parts with EscapeMarkdownV2
+func (tg *TelegramClient) SendMarkdownV2Message(message string) error {
+	_, err := tg.SendMessage(tg.ChatId, message, &MessageOptions{ParseMode: ParseModeMarkdownV2})
+	return err
+}
+
+// Send a HTML message to ChatId, escape untrusted parts with EscapeHTML
+func (tg *TelegramClient) SendHTMLMessage(message string) error {
+	_, err := tg.SendMessage(tg.ChatId, message, &MessageOptions{ParseMode: ParseModeHTML})
+	return err
+}
+
+// The bot library always targets api.telegram.org, requests are redirected to the configured endpoint here
+type endpointTransport struct {
+	endpoint *url.URL
+	base     http.RoundTripper
+}
+
+func (t *endpointTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.endpoint.Scheme
+	req.URL.Host = t.endpoint.Host
+	req.URL.Path = path.Join("/", t.endpoint.Path, req.URL.Path)
+	req.Host = t.endpoint.Host
+
+	return t.base.RoundTrip(req)
+}
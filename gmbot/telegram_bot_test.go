@@ -0,0 +1,224 @@
+package gmbot
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const testBotToken = "123456:TEST"
+
+type testBotRequest struct {
+	Method   string
+	Values   url.Values
+	FileName string
+	File     []byte
+}
+
+// Stand-in for the Bot API server, every call except getMe is recorded
+type testBotServer struct {
+	mu        sync.Mutex
+	requests  []testBotRequest
+	messageID int
+
+	// Optional override of the reply, return nil to fall back to the default message result
+	reply func(req testBotRequest) *tgbotapi.APIResponse
+}
+
+func newTestTelegramClient(t *testing.T, server *testBotServer) *TelegramClient {
+	t.Helper()
+
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	client, err := InitTelegramClientWithEndpoint(testBotToken, 1001, httpServer.URL)
+	if err != nil {
+		t.Fatalf("InitTelegramClientWithEndpoint err, msg: %v", err)
+	}
+	return client
+}
+
+func (s *testBotServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	prefix := "/bot" + testBotToken + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.NotFound(w, r)
+		return
+	}
+
+	req := testBotRequest{Method: strings.TrimPrefix(r.URL.Path, prefix)}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		r.ParseMultipartForm(1 << 20)
+		req.Values = url.Values(r.MultipartForm.Value)
+		for _, files := range r.MultipartForm.File {
+			f, _ := files[0].Open()
+			req.FileName = files[0].Filename
+			req.File, _ = io.ReadAll(f)
+			f.Close()
+		}
+	} else {
+		r.ParseForm()
+		req.Values = r.PostForm
+	}
+
+	if req.Method == "getMe" {
+		writeTestBotResult(w, tgbotapi.User{ID: 1, UserName: "test_bot"})
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.messageID++
+	messageID := s.messageID
+	reply := s.reply
+	s.mu.Unlock()
+
+	if reply != nil {
+		if rsp := reply(req); rsp != nil {
+			if rsp.ErrorCode != 0 {
+				w.WriteHeader(rsp.ErrorCode)
+			}
+			json.NewEncoder(w).Encode(rsp)
+			return
+		}
+	}
+
+	if req.Method == "deleteMessage" {
+		writeTestBotResult(w, true)
+		return
+	}
+
+	chatID, _ := strconv.ParseInt(req.Values.Get("chat_id"), 10, 64)
+	if id, err := strconv.Atoi(req.Values.Get("message_id")); err == nil {
+		messageID = id
+	}
+	writeTestBotResult(w, tgbotapi.Message{
+		MessageID: messageID,
+		Chat:      &tgbotapi.Chat{ID: chatID},
+		Text:      req.Values.Get("text"),
+		Caption:   req.Values.Get("caption"),
+	})
+}
+
+func (s *testBotServer) Requests() []testBotRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]testBotRequest(nil), s.requests...)
+}
+
+func writeTestBotResult(w http.ResponseWriter, result interface{}) {
+	data, _ := json.Marshal(result)
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: data})
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       func(string) string
+		input    string
+		expected string
+	}{
+		{"MarkdownV2", EscapeMarkdownV2, "balance: 1.5 (AR)! [x]_y_", `balance: 1\.5 \(AR\)\! \[x\]\_y\_`},
+		{"MarkdownV2 backslash", EscapeMarkdownV2, `a\b`, `a\\b`},
+		{"MarkdownV2 code", EscapeMarkdownV2Code, "a`b\\c.d", "a\\`b\\\\c.d"},
+		{"MarkdownV2 url", EscapeMarkdownV2URL, "https://x.io/a_(b)", `https://x.io/a_(b\)`},
+		{"Markdown", EscapeMarkdown, "file_name *bold*", `file\_name \*bold\*`},
+		{"HTML", EscapeHTML, `<b>"a" & b</b>`, "&lt;b&gt;&quot;a&quot; &amp; b&lt;/b&gt;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := tt.fn(tt.input); result != tt.expected {
+				t.Errorf("expected %v, but got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestSendMessage(t *testing.T) {
+	server := &testBotServer{}
+	client := newTestTelegramClient(t, server)
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonURL("Open", "https://example.com"),
+	))
+	message, err := client.SendMessage(2002, "<b>hi</b>", &MessageOptions{
+		ParseMode:           ParseModeHTML,
+		DisableNotification: true,
+		MessageThreadID:     7,
+		ReplyToMessageID:    3,
+		ReplyMarkup:         markup,
+	})
+	if err != nil {
+		t.Fatalf("SendMessage err, msg: %v", err)
+	}
+	if message.Chat.ID != 2002 || message.MessageID == 0 {
+		t.Errorf("unexpected message %+v", message)
+	}
+
+	if err := client.SendMarkdownV2Message(EscapeMarkdownV2("done.")); err != nil {
+		t.Fatalf("SendMarkdownV2Message err, msg: %v", err)
+	}
+
+	requests := server.Requests()
+	v := requests[0].Values
+	if requests[0].Method != "sendMessage" || v.Get("chat_id") != "2002" || v.Get("parse_mode") != "HTML" ||
+		v.Get("disable_notification") != "true" || v.Get("message_thread_id") != "7" || v.Get("reply_to_message_id") != "3" ||
+		!strings.Contains(v.Get("reply_markup"), `"url":"https://example.com"`) {
+		t.Errorf("unexpected request %+v", requests[0])
+	}
+
+	v = requests[1].Values
+	if v.Get("chat_id") != "1001" || v.Get("parse_mode") != ParseModeMarkdownV2 || v.Get("text") != `done\.` {
+		t.Errorf("unexpected request %+v", requests[1])
+	}
+}
+
+func TestSendFileEditDelete(t *testing.T) {
+	server := &testBotServer{}
+	client := newTestTelegramClient(t, server)
+
+	if _, err := client.SendPhoto(1001, NewInputFileBytes("chart.png", []byte("png")), "chart", &MessageOptions{DisableNotification: true}); err != nil {
+		t.Fatalf("SendPhoto err, msg: %v", err)
+	}
+
+	message, err := client.SendDocument(1001, NewInputFileBytes("report.csv", []byte("a,b")), "", nil)
+	if err != nil {
+		t.Fatalf("SendDocument err, msg: %v", err)
+	}
+
+	if _, err := client.EditMessageText(1001, message.MessageID, "edited", nil); err != nil {
+		t.Fatalf("EditMessageText err, msg: %v", err)
+	}
+
+	if err := client.DeleteMessage(1001, message.MessageID); err != nil {
+		t.Fatalf("DeleteMessage err, msg: %v", err)
+	}
+
+	requests := server.Requests()
+	if requests[0].Method != "sendPhoto" || requests[0].FileName != "chart.png" || string(requests[0].File) != "png" ||
+		requests[0].Values.Get("caption") != "chart" || requests[0].Values.Get("disable_notification") != "true" {
+		t.Errorf("unexpected request %+v", requests[0])
+	}
+	if requests[1].Method != "sendDocument" || string(requests[1].File) != "a,b" {
+		t.Errorf("unexpected request %+v", requests[1])
+	}
+	if requests[2].Method != "editMessageText" || requests[2].Values.Get("message_id") != strconv.Itoa(message.MessageID) {
+		t.Errorf("unexpected request %+v", requests[2])
+	}
+	if requests[3].Method != "deleteMessage" {
+		t.Errorf("unexpected request %+v", requests[3])
+	}
+
+	if _, err := client.SendPhoto(1001, InputFile{}, "", nil); err == nil {
+		t.Error("expected an error, but got nil")
+	}
+}
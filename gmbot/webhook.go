@@ -0,0 +1,234 @@
+package gmbot
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	WebhookSignatureHeader = "X-Signature-256"
+	WebhookTimestampHeader = "X-Signature-Timestamp"
+)
+
+var defaultWebhookTimeout = 10 * time.Second
+
+// Non 2xx answer of a webhook endpoint
+type WebhookError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // requested delay of 429 responses
+}
+
+func (e *WebhookError) Error() string {
+	return fmt.Sprintf("webhook responded %d %s", e.StatusCode, e.Body)
+}
+
+func (s Severity) color() int {
+	switch {
+	case s >= SeverityCritical:
+		return 0xD32F2F
+	case s >= SeverityError:
+		return 0xF57C00
+	case s >= SeverityWarning:
+		return 0xFBC02D
+	default:
+		return 0x1976D2
+	}
+}
+
+// Post to Slack compatible incoming webhooks, such as Slack, Mattermost or Rocket.Chat
+type SlackNotifier struct {
+	WebhookURL string
+	Client     *http.Client // http.Client with a 10 seconds timeout when nil
+}
+
+func NewSlackNotifier(webhookURL string) *SlackNotifier {
+	return &SlackNotifier{WebhookURL: webhookURL}
+}
+
+func (s *SlackNotifier) Notify(ctx context.Context, notification Notification) error {
+	payload := map[string]interface{}{
+		"text": fmt.Sprintf("%s %s: %s", notification.Severity.icon(), notification.Severity, escapeSlack(notification.Title)),
+		"attachments": []map[string]interface{}{{
+			"color": fmt.Sprintf("#%06X", notification.Severity.color()),
+			"title": escapeSlack(notification.Title),
+			"text":  escapeSlack(notification.Message),
+		}},
+	}
+
+	return postWebhook(ctx, s.Client, s.WebhookURL, payload, nil)
+}
+
+// Slack only requires the control characters of its markup to be escaped
+func escapeSlack(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+type DiscordNotifier struct {
+	WebhookURL string
+	Username   string       // overrides the name of the webhook when set
+	Client     *http.Client // http.Client with a 10 seconds timeout when nil
+}
+
+func NewDiscordNotifier(webhookURL string) *DiscordNotifier {
+	return &DiscordNotifier{WebhookURL: webhookURL}
+}
+
+func (d *DiscordNotifier) Notify(ctx context.Context, notification Notification) error {
+	payload := map[string]interface{}{
+		"embeds": []map[string]interface{}{{
+			"title":       truncateRunes(fmt.Sprintf("%s %s: %s", notification.Severity.icon(), notification.Severity, notification.Title), 256),
+			"description": truncateRunes(notification.Message, 4096),
+			"color":       notification.Severity.color(),
+		}},
+		"allowed_mentions": map[string]interface{}{"parse": []string{}},
+	}
+	if d.Username != "" {
+		payload["username"] = d.Username
+	}
+
+	return postWebhook(ctx, d.Client, d.WebhookURL, payload, nil)
+}
+
+func truncateRunes(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+// Post notifications as JSON to any endpoint, signed with HMAC-SHA256 when Secret is set
+type WebhookNotifier struct {
+	URL    string
+	Secret string
+	Header http.Header  // extra request headers, e.g. Authorization
+	Client *http.Client // http.Client with a 10 seconds timeout when nil
+}
+
+// Body of the requests of WebhookNotifier
+type WebhookPayload struct {
+	Severity  string `json:"severity"`
+	Title     string `json:"title"`
+	Message   string `json:"message"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+/*
+Create a notifier posting a WebhookPayload to url.
+With a secret, the X-Signature-256 header holds "sha256=" and the hex HMAC-SHA256 of "<timestamp>.<body>",
+the timestamp is sent in X-Signature-Timestamp, receivers check both with VerifyWebhookSignature.
+*/
+func NewWebhookNotifier(url, secret string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Secret: secret}
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, notification Notification) error {
+	payload := WebhookPayload{
+		Severity:  notification.Severity.String(),
+		Title:     notification.Title,
+		Message:   notification.Message,
+		Timestamp: time.Now().Unix(),
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("json.Marshal %v", err)
+	}
+
+	header := http.Header{}
+	for key, values := range w.Header {
+		header[key] = values
+	}
+	if w.Secret != "" {
+		timestamp := strconv.FormatInt(payload.Timestamp, 10)
+		header.Set(WebhookTimestampHeader, timestamp)
+		header.Set(WebhookSignatureHeader, SignWebhook(w.Secret, timestamp, body))
+	}
+
+	return postWebhookBody(ctx, w.Client, w.URL, body, header)
+}
+
+// Signature of a webhook body in the X-Signature-256 format
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+/*
+Check the signature of a request sent by WebhookNotifier, timestamps older than maxAge are rejected to prevent replays
+
+	body, _ := io.ReadAll(r.Body)
+	err := gmbot.VerifyWebhookSignature(secret, r.Header.Get(gmbot.WebhookTimestampHeader), body, r.Header.Get(gmbot.WebhookSignatureHeader), 5*time.Minute)
+*/
+func VerifyWebhookSignature(secret, timestamp string, body []byte, signature string, maxAge time.Duration) error {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook timestamp %q", timestamp)
+	}
+
+	if maxAge > 0 {
+		if age := time.Since(time.Unix(unix, 0)); age > maxAge || age < -maxAge {
+			return fmt.Errorf("webhook timestamp %d outside of %s", unix, maxAge)
+		}
+	}
+
+	if !hmac.Equal([]byte(SignWebhook(secret, timestamp, body)), []byte(signature)) {
+		return fmt.Errorf("invalid webhook signature")
+	}
+	return nil
+}
+
+func postWebhook(ctx context.Context, client *http.Client, url string, payload interface{}, header http.Header) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("json.Marshal %v", err)
+	}
+	return postWebhookBody(ctx, client, url, body, header)
+}
+
+func postWebhookBody(ctx context.Context, client *http.Client, url string, body []byte, header http.Header) error {
+	if client == nil {
+		client = &http.Client{Timeout: defaultWebhookTimeout}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("http.NewRequest %v", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("client.Do %w", err)
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(rsp.Body, 1024))
+		webhookErr := &WebhookError{StatusCode: rsp.StatusCode, Body: strings.TrimSpace(string(data))}
+		if seconds, err := strconv.Atoi(rsp.Header.Get("Retry-After")); err == nil {
+			webhookErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+		return webhookErr
+	}
+
+	io.Copy(io.Discard, rsp.Body)
+	return nil
+}
@@ -0,0 +1,265 @@
+package gmrouter
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	gm "github.com/W3Tools/go-modules"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	defaultIdempotencyPrefix  = "idempotency:"
+	defaultIdempotencyTTL     = 24 * time.Hour
+	defaultIdempotencyLockTTL = 30 * time.Second
+	defaultIdempotencyMaxBody = 1 << 20
+)
+
+// Headers of a response that belong to its connection or to the client that got it, never replayed to another request
+var idempotencyExcludedHeaders = []string{
+	"Set-Cookie",
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+	"Content-Length",
+	"Date",
+}
+
+// ScopeFunc sharing the keys between all clients, only for keys that can not collide such as server generated ids
+func IdempotencyGlobalScope(ctx *gin.Context) string {
+	return ""
+}
+
+type IdempotencyOptions struct {
+	RedisClient *gm.RedisClient
+	Header      string           // "Idempotency-Key" when empty
+	KeyPrefix   string           // prefix of the redis keys, "idempotency:" when empty
+	TTL         time.Duration    // how long responses are replayed, 24 hours when zero
+	LockTTL     time.Duration    // lease of the lock held while the first request runs, renewed until it completes, 30 seconds when zero
+	ScopeFunc   RateLimitKeyFunc // separates the keys of different clients, required, e.g. RateLimitByJwtSubject, IdempotencyGlobalScope shares them
+	MaxBodySize int64            // largest request body, bigger ones are rejected with 413, 1 MiB when zero
+	Required    bool             // reject requests without the header instead of running them normally
+}
+
+// A response kept for replays, Fingerprint identifies the request that produced it
+type idempotentResponse struct {
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+}
+
+/*
+Run a request once per Idempotency-Key, repeats get the stored status, headers and body with Idempotent-Replayed: true.
+A repeat while the first request runs is rejected with 409 Conflict, a key reused for another method, path or body with 422.
+Server errors are not stored so the request can be retried, cookies and hop-by-hop headers are not replayed.
+
+	payments := group.Group("/payments")
+	payments.Use(gmrouter.IdempotencyMiddleware(gmrouter.IdempotencyOptions{
+		RedisClient: redisClient,
+		ScopeFunc:   gmrouter.RateLimitByJwtSubject(jwtClient),
+		Required:    true,
+	}))
+*/
+func IdempotencyMiddleware(options IdempotencyOptions) gin.HandlerFunc {
+	// Scoping by IP would run retries arriving from another address again, the scope has to be chosen
+	if options.ScopeFunc == nil {
+		panic("gmrouter: IdempotencyOptions.ScopeFunc is required")
+	}
+	if options.Header == "" {
+		options.Header = IdempotencyKeyHeader
+	}
+	if options.KeyPrefix == "" {
+		options.KeyPrefix = defaultIdempotencyPrefix
+	}
+	if options.TTL <= 0 {
+		options.TTL = defaultIdempotencyTTL
+	}
+	if options.LockTTL <= 0 {
+		options.LockTTL = defaultIdempotencyLockTTL
+	}
+	if options.MaxBodySize <= 0 {
+		options.MaxBodySize = defaultIdempotencyMaxBody
+	}
+
+	return func(ctx *gin.Context) {
+		r := Router{ApiContext: ctx}
+
+		// Safe methods are idempotent already
+		switch ctx.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			ctx.Next()
+			return
+		}
+
+		idempotencyKey := r.RequestHeaderGet(options.Header)
+		if idempotencyKey == "" {
+			if options.Required {
+				r.ApiResponse(http.StatusBadRequest, map[string]interface{}{"error": options.Header + " header is required"})
+				ctx.Abort()
+				return
+			}
+			ctx.Next()
+			return
+		}
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			r.ApiResponse(http.StatusBadRequest, map[string]interface{}{"error": options.Header + " header is too long"})
+			ctx.Abort()
+			return
+		}
+
+		key := options.KeyPrefix
+		if scope := options.ScopeFunc(ctx); scope != "" {
+			key += scope + ":"
+		}
+		key += idempotencyKey
+
+		fingerprint, err := requestFingerprint(ctx, options.MaxBodySize)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			r.ApiResponse(http.StatusRequestEntityTooLarge, map[string]interface{}{"error": "request body is too large"})
+			ctx.Abort()
+			return
+		}
+		if err != nil {
+			r.ApiResponseBadRequest()
+			ctx.Abort()
+			return
+		}
+
+		redisClient := options.RedisClient.WithContext(ctx.Request.Context())
+		if replayed, err := replayIdempotentResponse(redisClient, ctx, options.Header, key, fingerprint); err != nil || replayed {
+			if err != nil {
+				r.ApiResponseInternalServerError()
+			}
+			ctx.Abort()
+			return
+		}
+
+		// The response of a handler that ran must be stored even when the client disconnected, its retry would run it again
+		storeClient := options.RedisClient.WithContext(context.WithoutCancel(ctx.Request.Context()))
+
+		lock, err := storeClient.TryLock(key+":lock", &gm.LockOptions{TTL: options.LockTTL})
+		if errors.Is(err, gm.ErrLockNotObtained) {
+			r.ApiResponseConflict(map[string]interface{}{"error": "a request with this " + options.Header + " is in progress"})
+			ctx.Abort()
+			return
+		}
+		if err != nil {
+			r.ApiResponseInternalServerError()
+			ctx.Abort()
+			return
+		}
+		defer lock.Unlock()
+
+		// The first request may have completed between the check and the lock
+		if replayed, err := replayIdempotentResponse(redisClient, ctx, options.Header, key, fingerprint); err != nil || replayed {
+			if err != nil {
+				r.ApiResponseInternalServerError()
+			}
+			ctx.Abort()
+			return
+		}
+
+		writer := &captureWriter{ResponseWriter: ctx.Writer}
+		ctx.Writer = writer
+		ctx.Next()
+
+		status := writer.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+
+		header := writer.Header().Clone()
+		for _, name := range idempotencyExcludedHeaders {
+			header.Del(name)
+		}
+
+		data, err := json.Marshal(idempotentResponse{Fingerprint: fingerprint, Status: status, Header: header, Body: writer.body.Bytes()})
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		if err := storeClient.Set(key, data, options.TTL).Err(); err != nil {
+			ctx.Error(err)
+		}
+	}
+}
+
+// Write the stored response of key, returns false when there is none
+func replayIdempotentResponse(redisClient *gm.RedisClient, ctx *gin.Context, header, key, fingerprint string) (bool, error) {
+	data, err := redisClient.Get(key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var response idempotentResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return false, err
+	}
+
+	if response.Fingerprint != fingerprint {
+		r := Router{ApiContext: ctx}
+		r.ApiResponseUnprocessableEntity(map[string]interface{}{"error": "the " + header + " was used for a different request"})
+		return true, nil
+	}
+
+	for name, values := range response.Header {
+		ctx.Writer.Header()[name] = values
+	}
+	ctx.Header(IdempotentReplayedHeader, "true")
+	ctx.Data(response.Status, response.Header.Get("Content-Type"), response.Body)
+	return true, nil
+}
+
+// Hash of the method, URI and body, the body is restored for the handlers
+func requestFingerprint(ctx *gin.Context, maxBodySize int64) (string, error) {
+	var body []byte
+	if ctx.Request.Body != nil {
+		var err error
+		if body, err = io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxBodySize)); err != nil {
+			return "", err
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(ctx.Request.Method + " " + ctx.Request.URL.RequestURI() + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Copies the response body while it is written
+type captureWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (writer *captureWriter) Write(data []byte) (int, error) {
+	writer.body.Write(data)
+	return writer.ResponseWriter.Write(data)
+}
+
+func (writer *captureWriter) WriteString(s string) (int, error) {
+	writer.body.WriteString(s)
+	return writer.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,194 @@
+package gmrouter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	gm "github.com/W3Tools/go-modules"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+)
+
+func newTestIdempotencyEngine(t *testing.T, handler gin.HandlerFunc) *gin.Engine {
+	t.Helper()
+	return newTestIdempotencyEngineWithOptions(t, IdempotencyOptions{
+		ScopeFunc: func(ctx *gin.Context) string { return ctx.GetHeader("X-User") },
+		Required:  true,
+	}, handler)
+}
+
+func newTestIdempotencyEngineWithOptions(t *testing.T, options IdempotencyOptions, handler gin.HandlerFunc) *gin.Engine {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	redisClient, err := gm.InitRedisFromURL(context.Background(), "redis://"+server.Addr())
+	if err != nil {
+		t.Fatalf("InitRedisFromURL err, msg: %v", err)
+	}
+	t.Cleanup(func() { redisClient.Close() })
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	options.RedisClient = redisClient
+	engine.Use(IdempotencyMiddleware(options))
+	engine.POST("/payments", handler)
+	return engine
+}
+
+func postIdempotent(engine *gin.Engine, key, user, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	req.Header.Set("X-User", user)
+	rsp := httptest.NewRecorder()
+	engine.ServeHTTP(rsp, req)
+	return rsp
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	var calls atomic.Int64
+	engine := newTestIdempotencyEngine(t, func(ctx *gin.Context) {
+		r := Router{ApiContext: ctx}
+		ctx.Header("X-Payment-Id", "pay-1")
+		r.ApiResponse(http.StatusCreated, map[string]interface{}{"call": calls.Add(1)})
+	})
+
+	first := postIdempotent(engine, "key-1", "alice", `{"amount":10}`)
+	if first.Code != http.StatusCreated || first.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Fatalf("unexpected first response %d %v", first.Code, first.Header())
+	}
+
+	replay := postIdempotent(engine, "key-1", "alice", `{"amount":10}`)
+	if replay.Code != http.StatusCreated || replay.Body.String() != first.Body.String() ||
+		replay.Header().Get("X-Payment-Id") != "pay-1" || replay.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("expected the stored response, but got %d %s %v", replay.Code, replay.Body.String(), replay.Header())
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected the handler to run once, but ran %d times", calls.Load())
+	}
+
+	if rsp := postIdempotent(engine, "key-1", "alice", `{"amount":99}`); rsp.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a different body, but got %d", rsp.Code)
+	}
+	if rsp := postIdempotent(engine, "key-1", "bob", `{"amount":10}`); rsp.Code != http.StatusCreated || calls.Load() != 2 {
+		t.Errorf("expected keys to be scoped per user, but got %d after %d calls", rsp.Code, calls.Load())
+	}
+	if rsp := postIdempotent(engine, "", "alice", `{}`); rsp.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without key, but got %d", rsp.Code)
+	}
+}
+
+func TestIdempotencyMiddlewareConcurrent(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	engine := newTestIdempotencyEngine(t, func(ctx *gin.Context) {
+		close(started)
+		<-release
+		r := Router{ApiContext: ctx}
+		r.ApiResponseOk("done")
+	})
+
+	result := make(chan *httptest.ResponseRecorder)
+	go func() { result <- postIdempotent(engine, "key-1", "alice", `{}`) }()
+	<-started
+
+	if rsp := postIdempotent(engine, "key-1", "alice", `{}`); rsp.Code != http.StatusConflict {
+		t.Errorf("expected 409 while the first request runs, but got %d", rsp.Code)
+	}
+	close(release)
+	if rsp := <-result; rsp.Code != http.StatusOK {
+		t.Errorf("expected the first request to succeed, but got %d", rsp.Code)
+	}
+	if rsp := postIdempotent(engine, "key-1", "alice", `{}`); rsp.Code != http.StatusOK || rsp.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("expected a replay after completion, but got %d", rsp.Code)
+	}
+}
+
+func TestIdempotencyMiddlewareServerError(t *testing.T) {
+	var calls atomic.Int64
+	engine := newTestIdempotencyEngine(t, func(ctx *gin.Context) {
+		r := Router{ApiContext: ctx}
+		if calls.Add(1) == 1 {
+			r.ApiResponseInternalServerError()
+			return
+		}
+		r.ApiResponseOk("ok")
+	})
+
+	postIdempotent(engine, "key-1", "alice", `{}`)
+	if rsp := postIdempotent(engine, "key-1", "alice", `{}`); rsp.Code != http.StatusOK || calls.Load() != 2 {
+		t.Errorf("expected a retry after a server error, but got %d after %d calls", rsp.Code, calls.Load())
+	}
+}
+
+func TestIdempotencyMiddlewareDefaults(t *testing.T) {
+	var calls atomic.Int64
+	engine := newTestIdempotencyEngineWithOptions(t, IdempotencyOptions{ScopeFunc: IdempotencyGlobalScope, MaxBodySize: 16}, func(ctx *gin.Context) {
+		r := Router{ApiContext: ctx}
+		ctx.SetCookie("session", "client-secret", 60, "/", "", false, true)
+		r.ApiResponseOk(calls.Add(1))
+	})
+
+	post := func(ip, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		req.RemoteAddr = ip + ":1234"
+		rsp := httptest.NewRecorder()
+		engine.ServeHTTP(rsp, req)
+		return rsp
+	}
+
+	if rsp := post("10.0.0.1", `{}`); rsp.Header().Get("Set-Cookie") == "" {
+		t.Fatal("expected the first response to set its cookie")
+	}
+
+	// The retry arrives from another address after a network handover, it is replayed without the cookie of the first response
+	replay := post("10.0.0.2", `{}`)
+	if replay.Header().Get(IdempotentReplayedHeader) != "true" || replay.Header().Get("Set-Cookie") != "" || calls.Load() != 1 {
+		t.Errorf("expected a replay without Set-Cookie, but got %v after %d calls", replay.Header(), calls.Load())
+	}
+
+	if rsp := post("10.0.0.3", strings.Repeat("x", 17)); rsp.Code != http.StatusRequestEntityTooLarge || calls.Load() != 1 {
+		t.Errorf("expected 413 for a body over MaxBodySize, but got %d", rsp.Code)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected IdempotencyMiddleware to panic without ScopeFunc")
+		}
+	}()
+	IdempotencyMiddleware(IdempotencyOptions{})
+}
+
+func TestIdempotencyMiddlewareClientGone(t *testing.T) {
+	var calls atomic.Int64
+	cancels := map[string]context.CancelFunc{}
+	engine := newTestIdempotencyEngine(t, func(ctx *gin.Context) {
+		r := Router{ApiContext: ctx}
+		calls.Add(1)
+		// The client times out and disconnects while the handler runs
+		cancels[ctx.GetHeader("X-User")]()
+		r.ApiResponse(http.StatusCreated, "paid")
+	})
+
+	post := func() *httptest.ResponseRecorder {
+		reqCtx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		cancels["alice"] = cancel
+		req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(`{}`)).WithContext(reqCtx)
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		req.Header.Set("X-User", "alice")
+		rsp := httptest.NewRecorder()
+		engine.ServeHTTP(rsp, req)
+		return rsp
+	}
+
+	post()
+	if rsp := post(); rsp.Header().Get(IdempotentReplayedHeader) != "true" || calls.Load() != 1 {
+		t.Errorf("expected the retry to be replayed, but got %v after %d calls", rsp.Header(), calls.Load())
+	}
+}
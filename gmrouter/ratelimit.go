@@ -0,0 +1,141 @@
+package gmrouter
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	gm "github.com/W3Tools/go-modules"
+	"github.com/W3Tools/go-modules/gmjwt"
+	"github.com/gin-gonic/gin"
+)
+
+// Limiter key of a request, requests with an empty key are not limited
+type RateLimitKeyFunc func(ctx *gin.Context) string
+
+type RateLimitOptions struct {
+	Limiter    gm.RateLimiter
+	KeyFunc    RateLimitKeyFunc // RateLimitByIP when nil
+	JsonRPC    bool             // reject with a JSON-RPC "Limit exceeded" error instead of the Response envelope
+	FailClosed bool             // reject requests when the limiter fails, they are let through by default
+}
+
+func RateLimitByIP(ctx *gin.Context) string {
+	return "ip:" + ctx.ClientIP()
+}
+
+/*
+Limit by the subject of the JWT in the Authorization bearer, Token or Apitoken header.
+The seed is used for tokens without a subject, requests without a valid token are limited by IP.
+*/
+func RateLimitByJwtSubject(jwtClient *gmjwt.JwtClient) RateLimitKeyFunc {
+	return func(ctx *gin.Context) string {
+		r := Router{ApiContext: ctx}
+
+		token := strings.TrimSpace(strings.TrimPrefix(r.RequestHeaderGet("Authorization"), "Bearer "))
+		for _, header := range []string{"Token", "Apitoken"} {
+			if token == "" {
+				token = r.RequestHeaderGet(header)
+			}
+		}
+
+		claims, err := jwtClient.ParseJwtToken(token)
+		if err != nil || claims == nil {
+			return RateLimitByIP(ctx)
+		}
+
+		if claims.Subject != "" {
+			return "sub:" + claims.Subject
+		}
+		seed, err := json.Marshal(claims.Seed)
+		if err != nil {
+			return RateLimitByIP(ctx)
+		}
+		return "sub:" + string(seed)
+	}
+}
+
+/*
+Reject requests over the limit with 429 Too Many Requests and a Retry-After header,
+every limited response carries X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset
+
+	group := gmrouter.InitRouter("/api", false)
+	group.Use(gmrouter.RateLimitMiddleware(gmrouter.RateLimitOptions{
+		Limiter: redisClient.NewTokenBucketLimiter("ratelimit:api:", 10, 20),
+		KeyFunc: gmrouter.RateLimitByJwtSubject(jwtClient),
+	}))
+*/
+func RateLimitMiddleware(options RateLimitOptions) gin.HandlerFunc {
+	keyFunc := options.KeyFunc
+	if keyFunc == nil {
+		keyFunc = RateLimitByIP
+	}
+
+	return func(ctx *gin.Context) {
+		r := Router{ApiContext: ctx}
+
+		key := keyFunc(ctx)
+		if key == "" {
+			ctx.Next()
+			return
+		}
+
+		result, err := options.Limiter.Allow(ctx.Request.Context(), key)
+		if err != nil {
+			if options.FailClosed {
+				r.ApiResponseInternalServerError()
+				ctx.Abort()
+				return
+			}
+			ctx.Next()
+			return
+		}
+
+		ctx.Header("X-RateLimit-Limit", strconv.FormatInt(result.Limit, 10))
+		ctx.Header("X-RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+		ctx.Header("X-RateLimit-Reset", strconv.FormatInt(ceilSeconds(result.ResetAfter), 10))
+
+		if result.Allowed {
+			ctx.Next()
+			return
+		}
+
+		retryAfter := ceilSeconds(result.RetryAfter)
+		ctx.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+		data := map[string]interface{}{"retryAfter": retryAfter}
+
+		if options.JsonRPC {
+			r.JsonRPCResponseLimitExceeded(peekJsonRPCID(ctx), data)
+		} else {
+			r.ApiResponseTooManyRequests(data)
+		}
+		ctx.Abort()
+	}
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// Id of a JSON-RPC request, the body is restored for the handlers
+func peekJsonRPCID(ctx *gin.Context) json.RawMessage {
+	if ctx.Request.Body == nil {
+		return nil
+	}
+
+	body, err := io.ReadAll(ctx.Request.Body)
+	ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+
+	var request JsonRPCRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil
+	}
+	return request.ID
+}
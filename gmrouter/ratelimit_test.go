@@ -0,0 +1,138 @@
+package gmrouter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gm "github.com/W3Tools/go-modules"
+	"github.com/W3Tools/go-modules/gmjwt"
+	"github.com/gin-gonic/gin"
+)
+
+// Allows the first Limit requests of every key
+type testLimiter struct {
+	Limit int64
+	Err   error
+	keys  map[string]int64
+}
+
+func (l *testLimiter) Allow(ctx context.Context, key string) (*gm.RateLimitResult, error) {
+	if l.Err != nil {
+		return nil, l.Err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if l.keys == nil {
+		l.keys = make(map[string]int64)
+	}
+
+	l.keys[key]++
+	if l.keys[key] > l.Limit {
+		return &gm.RateLimitResult{Limit: l.Limit, RetryAfter: 1500 * time.Millisecond, ResetAfter: 10 * time.Second}, nil
+	}
+	return &gm.RateLimitResult{Allowed: true, Limit: l.Limit, Remaining: l.Limit - l.keys[key], ResetAfter: 10 * time.Second}, nil
+}
+
+func newTestRateLimitEngine(options RateLimitOptions) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(RateLimitMiddleware(options))
+	engine.POST("/", func(ctx *gin.Context) {
+		r := Router{ApiContext: ctx}
+		r.ApiResponseOk("ok")
+	})
+	return engine
+}
+
+func serveTestRequest(engine *gin.Engine, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.RemoteAddr = "10.0.0.1:1234"
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rsp := httptest.NewRecorder()
+	engine.ServeHTTP(rsp, req)
+	return rsp
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	limiter := &testLimiter{Limit: 2}
+	engine := newTestRateLimitEngine(RateLimitOptions{Limiter: limiter})
+
+	for i := 0; i < 2; i++ {
+		rsp := serveTestRequest(engine, "", nil)
+		if rsp.Code != http.StatusOK || rsp.Header().Get("X-RateLimit-Remaining") != []string{"1", "0"}[i] ||
+			rsp.Header().Get("X-RateLimit-Limit") != "2" || rsp.Header().Get("X-RateLimit-Reset") != "10" {
+			t.Errorf("request %d: unexpected response %d %v", i, rsp.Code, rsp.Header())
+		}
+	}
+
+	rsp := serveTestRequest(engine, "", nil)
+	if rsp.Code != http.StatusTooManyRequests || rsp.Header().Get("Retry-After") != "2" {
+		t.Fatalf("expected 429 with Retry-After 2, but got %d %v", rsp.Code, rsp.Header())
+	}
+	var response Response
+	json.Unmarshal(rsp.Body.Bytes(), &response)
+	if response.Code != http.StatusTooManyRequests || response.Message != "Too Many Requests" {
+		t.Errorf("unexpected response %+v", response)
+	}
+	if _, ok := limiter.keys["ip:10.0.0.1"]; !ok {
+		t.Errorf("expected requests to be limited by IP, got keys %v", limiter.keys)
+	}
+}
+
+func TestRateLimitMiddlewareJsonRPC(t *testing.T) {
+	engine := newTestRateLimitEngine(RateLimitOptions{Limiter: &testLimiter{Limit: 0}, JsonRPC: true})
+
+	rsp := serveTestRequest(engine, `{"jsonrpc":"2.0","id":7,"method":"eth_call"}`, nil)
+	var response JsonRPCResponse
+	json.Unmarshal(rsp.Body.Bytes(), &response)
+	if string(response.ID) != "7" || response.Error == nil || response.Error.Code != -32005 || rsp.Header().Get("Retry-After") != "2" {
+		t.Errorf("unexpected response %s", rsp.Body.String())
+	}
+}
+
+func TestRateLimitMiddlewareFailure(t *testing.T) {
+	failing := &testLimiter{Err: errors.New("redis down")}
+
+	if rsp := serveTestRequest(newTestRateLimitEngine(RateLimitOptions{Limiter: failing}), "", nil); rsp.Code != http.StatusOK {
+		t.Errorf("expected requests to pass when the limiter fails, but got %d", rsp.Code)
+	}
+	if rsp := serveTestRequest(newTestRateLimitEngine(RateLimitOptions{Limiter: failing, FailClosed: true}), "", nil); rsp.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 with FailClosed, but got %d", rsp.Code)
+	}
+
+	// The limiter runs on the request context, a client that went away does not wait for redis
+	requestCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodPost, "/", nil).WithContext(requestCtx)
+	rsp := httptest.NewRecorder()
+	newTestRateLimitEngine(RateLimitOptions{Limiter: &testLimiter{Limit: 1}, FailClosed: true}).ServeHTTP(rsp, req)
+	if rsp.Code != http.StatusInternalServerError {
+		t.Errorf("expected the limiter to get the canceled request context, but got %d", rsp.Code)
+	}
+}
+
+func TestRateLimitByJwtSubject(t *testing.T) {
+	jwtClient := gmjwt.InitJwtClient("secret", 60)
+	limiter := &testLimiter{Limit: 1}
+	engine := newTestRateLimitEngine(RateLimitOptions{Limiter: limiter, KeyFunc: RateLimitByJwtSubject(jwtClient)})
+
+	token, _ := jwtClient.NewJwtToken(map[string]string{"user": "alice"})
+	serveTestRequest(engine, "", http.Header{"Authorization": {"Bearer " + token}})
+	serveTestRequest(engine, "", http.Header{"Token": {"invalid"}})
+
+	if _, ok := limiter.keys[`sub:{"user":"alice"}`]; !ok {
+		t.Errorf("expected the token seed as key, got keys %v", limiter.keys)
+	}
+	if _, ok := limiter.keys["ip:10.0.0.1"]; !ok {
+		t.Errorf("expected invalid tokens to fall back to the IP, got keys %v", limiter.keys)
+	}
+}
@@ -13,6 +13,9 @@ var DefaultMessage = map[int]string{
 	http.StatusBadRequest:          "BadRequest",
 	http.StatusInternalServerError: "Internal Server Error",
 	http.StatusUnauthorized:        "Unauthorized",
+	http.StatusTooManyRequests:     "Too Many Requests",
+	http.StatusConflict:            "Conflict",
+	http.StatusUnprocessableEntity: "Unprocessable Entity",
 }
 
 func (*Router) NewResponseMessage(code int, data interface{}) Response {
@@ -38,3 +41,15 @@ func (r *Router) ResponseMessageUnauthorized(data interface{}) Response {
 func (r *Router) ResponseMessageInternalServerError(data interface{}) Response {
 	return r.NewResponseMessage(http.StatusInternalServerError, data)
 }
+
+func (r *Router) ResponseMessageTooManyRequests(data interface{}) Response {
+	return r.NewResponseMessage(http.StatusTooManyRequests, data)
+}
+
+func (r *Router) ResponseMessageConflict(data interface{}) Response {
+	return r.NewResponseMessage(http.StatusConflict, data)
+}
+
+func (r *Router) ResponseMessageUnprocessableEntity(data interface{}) Response {
+	return r.NewResponseMessage(http.StatusUnprocessableEntity, data)
+}
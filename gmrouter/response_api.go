@@ -25,3 +25,15 @@ func (r *Router) ApiResponseUnauthorized() {
 func (r *Router) ApiResponseInternalServerError() {
 	r.ApiResponse(http.StatusInternalServerError, nil)
 }
+
+func (r *Router) ApiResponseTooManyRequests(data interface{}) {
+	r.ApiResponse(http.StatusTooManyRequests, data)
+}
+
+func (r *Router) ApiResponseConflict(data interface{}) {
+	r.ApiResponse(http.StatusConflict, data)
+}
+
+func (r *Router) ApiResponseUnprocessableEntity(data interface{}) {
+	r.ApiResponse(http.StatusUnprocessableEntity, data)
+}
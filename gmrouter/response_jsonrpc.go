@@ -40,6 +40,7 @@ const (
 	NoMoreParams   string = "No more params"
 	InternalError  string = "Internal server error"
 	ParseError     string = "Parse error"
+	LimitExceeded  string = "Limit exceeded"
 )
 
 var DefaultJsonRPCCode = map[string]int{
@@ -50,6 +51,7 @@ var DefaultJsonRPCCode = map[string]int{
 	NoMoreParams:   -32602,
 	InternalError:  -32603,
 	ParseError:     -32700,
+	LimitExceeded:  -32005,
 }
 
 func (r *Router) JsonRPCShouldBindJSON() (request *JsonRPCRequest, err error) {
@@ -115,6 +117,10 @@ func (r *Router) JsonRPCResponseNoMoreParams(id json.RawMessage, data interface{
 	r.JsonRPCResponse(id, DefaultJsonRPCCode[NoMoreParams], NoMoreParams, data)
 }
 
+func (r *Router) JsonRPCResponseLimitExceeded(id json.RawMessage, data interface{}) {
+	r.JsonRPCResponse(id, DefaultJsonRPCCode[LimitExceeded], LimitExceeded, data)
+}
+
 type JsonRPCHandlerFunc func(*Router, *JsonRPCRequest)
 
 func (r *Router) WrapperJsonRPCHandler(request *JsonRPCRequest, handlers ...JsonRPCHandlerFunc) {
@@ -0,0 +1,400 @@
+package gmrouter
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	gm "github.com/W3Tools/go-modules"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+const sessionContextKey = "gmrouter.session"
+
+var (
+	defaultSessionCookieName      = "session"
+	defaultSessionKeyPrefix       = "session:"
+	defaultSessionIdleTimeout     = 30 * time.Minute
+	defaultSessionAbsoluteTimeout = 12 * time.Hour
+)
+
+type SessionOptions struct {
+	RedisClient     *gm.RedisClient
+	Secret          []byte        // HMAC key signing the session cookie, required
+	CookieName      string        // "session" when empty
+	KeyPrefix       string        // prefix of the redis keys, "session:" when empty
+	IdleTimeout     time.Duration // lifetime without requests, 30 minutes when zero
+	AbsoluteTimeout time.Duration // lifetime since creation regardless of activity, 12 hours when zero
+	Path            string        // cookie path, "/" when empty
+	Domain          string
+	Secure          bool          // send the cookie over HTTPS only
+	SameSite        http.SameSite // http.SameSiteLaxMode when zero
+}
+
+// Values are kept as JSON so every request decodes them into the type it expects
+type sessionData struct {
+	Values    map[string]json.RawMessage `json:"values"`
+	Flashes   []string                   `json:"flashes,omitempty"`
+	CreatedAt int64                      `json:"createdAt"`
+}
+
+// Server-side session of a request, saved to redis before the response is written
+type Session struct {
+	id   string
+	data sessionData
+
+	modified    bool
+	destroyed   bool
+	regenerated string // previous id, deleted when the session is saved
+}
+
+// Id of the session, empty until a new session is saved
+func (session *Session) ID() string {
+	return session.id
+}
+
+func (session *Session) CreatedAt() time.Time {
+	return time.Unix(session.data.CreatedAt, 0)
+}
+
+// Decode the value of key into v, returns false when key is not set
+func (session *Session) Get(key string, v interface{}) (bool, error) {
+	raw, ok := session.data.Values[key]
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return false, fmt.Errorf("json.Unmarshal session value %s err %v", key, err)
+	}
+	return true, nil
+}
+
+func (session *Session) Set(key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("json.Marshal session value %s err %v", key, err)
+	}
+	session.data.Values[key] = raw
+	session.modified = true
+	return nil
+}
+
+func (session *Session) Delete(key string) {
+	if _, ok := session.data.Values[key]; ok {
+		delete(session.data.Values, key)
+		session.modified = true
+	}
+}
+
+// Message shown once, on the next request reading Flashes
+func (session *Session) AddFlash(message string) {
+	session.data.Flashes = append(session.data.Flashes, message)
+	session.modified = true
+}
+
+// Pending flash messages, they are removed from the session
+func (session *Session) Flashes() []string {
+	flashes := session.data.Flashes
+	if len(flashes) > 0 {
+		session.data.Flashes = nil
+		session.modified = true
+	}
+	return flashes
+}
+
+// Move the session to a new id, call it on login so an id planted before authentication becomes useless
+func (session *Session) Regenerate() {
+	if session.id != "" && session.regenerated == "" {
+		session.regenerated = session.id
+	}
+	session.id = ""
+	session.modified = true
+}
+
+// Delete the session and expire its cookie, call it on logout
+func (session *Session) Destroy() {
+	session.destroyed = true
+	session.data = sessionData{Values: map[string]json.RawMessage{}}
+}
+
+type sessionStore struct {
+	options SessionOptions
+	now     func() time.Time
+}
+
+/*
+Keep sessions in redis behind a signed, HttpOnly cookie.
+Sessions expire after IdleTimeout without requests and AbsoluteTimeout after their creation.
+
+	group.Use(gmrouter.SessionMiddleware(gmrouter.SessionOptions{
+		RedisClient: redisClient,
+		Secret:      []byte(os.Getenv("SESSION_SECRET")),
+		Secure:      true,
+	}))
+
+	group.POST("/login", func(ctx *gin.Context) {
+		r := gmrouter.Router{ApiContext: ctx}
+		r.Session().Regenerate()
+		r.SessionSet("userId", user.ID)
+		r.Session().AddFlash("Welcome back")
+	})
+*/
+func SessionMiddleware(options SessionOptions) gin.HandlerFunc {
+	return newSessionStore(options, time.Now).handle
+}
+
+func newSessionStore(options SessionOptions, now func() time.Time) *sessionStore {
+	if len(options.Secret) == 0 {
+		panic("gmrouter: SessionOptions.Secret is required")
+	}
+	if options.CookieName == "" {
+		options.CookieName = defaultSessionCookieName
+	}
+	if options.KeyPrefix == "" {
+		options.KeyPrefix = defaultSessionKeyPrefix
+	}
+	if options.IdleTimeout <= 0 {
+		options.IdleTimeout = defaultSessionIdleTimeout
+	}
+	if options.AbsoluteTimeout <= 0 {
+		options.AbsoluteTimeout = defaultSessionAbsoluteTimeout
+	}
+	if options.Path == "" {
+		options.Path = "/"
+	}
+	if options.SameSite == 0 {
+		options.SameSite = http.SameSiteLaxMode
+	}
+	return &sessionStore{options: options, now: now}
+}
+
+func (store *sessionStore) handle(ctx *gin.Context) {
+	redisClient := store.options.RedisClient.WithContext(ctx.Request.Context())
+
+	session, err := store.load(redisClient, ctx)
+	if err != nil {
+		r := Router{ApiContext: ctx}
+		r.ApiResponseInternalServerError()
+		ctx.Abort()
+		return
+	}
+	ctx.Set(sessionContextKey, session)
+
+	// The cookie has to be set before the handler writes the response
+	writer := &sessionWriter{ResponseWriter: ctx.Writer, commit: func() {
+		if err := store.save(redisClient, ctx, session); err != nil {
+			ctx.Error(err)
+		}
+	}}
+	ctx.Writer = writer
+	ctx.Next()
+	writer.commitOnce()
+}
+
+func (store *sessionStore) load(redisClient *gm.RedisClient, ctx *gin.Context) (*Session, error) {
+	session := &Session{data: sessionData{Values: map[string]json.RawMessage{}, CreatedAt: store.now().Unix()}}
+
+	cookie, err := ctx.Cookie(store.options.CookieName)
+	if err != nil {
+		return session, nil
+	}
+	id, ok := store.verify(cookie)
+	if !ok {
+		return session, nil
+	}
+
+	value, err := redisClient.Get(store.options.KeyPrefix + id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return session, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis.Get session err %v", err)
+	}
+
+	var data sessionData
+	if err := json.Unmarshal(value, &data); err != nil {
+		return session, nil
+	}
+	if data.Values == nil {
+		data.Values = map[string]json.RawMessage{}
+	}
+
+	// Past the absolute timeout the request starts over with a new session
+	if time.Unix(data.CreatedAt, 0).Add(store.options.AbsoluteTimeout).Before(store.now()) {
+		redisClient.Del(store.options.KeyPrefix + id)
+		return session, nil
+	}
+	session.id, session.data = id, data
+	return session, nil
+}
+
+// Lifetime left before the idle or the absolute timeout
+func (store *sessionStore) remaining(session *Session) time.Duration {
+	absolute := session.CreatedAt().Add(store.options.AbsoluteTimeout).Sub(store.now())
+	return min(store.options.IdleTimeout, absolute)
+}
+
+func (store *sessionStore) save(redisClient *gm.RedisClient, ctx *gin.Context, session *Session) error {
+	if session.destroyed {
+		if session.id != "" {
+			redisClient.Del(store.options.KeyPrefix + session.id)
+		}
+		if session.regenerated != "" {
+			redisClient.Del(store.options.KeyPrefix + session.regenerated)
+		}
+		if session.id != "" || session.regenerated != "" {
+			store.setCookie(ctx, "", -1)
+		}
+		return nil
+	}
+
+	// Anonymous visitors without values get no session
+	if session.id == "" && len(session.data.Values) == 0 && len(session.data.Flashes) == 0 {
+		return nil
+	}
+
+	// Redis would keep a key set with a zero ttl forever
+	ttl := max(store.remaining(session), time.Second)
+	if session.id != "" && !session.modified {
+		// Slide the idle timeout
+		if err := redisClient.Expire(store.options.KeyPrefix+session.id, ttl).Err(); err != nil {
+			return fmt.Errorf("redis.Expire session err %v", err)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(session.data)
+	if err != nil {
+		return fmt.Errorf("json.Marshal session err %v", err)
+	}
+
+	created := session.id == ""
+	if created {
+		if session.id, err = newSessionID(); err != nil {
+			return err
+		}
+	}
+	if err := redisClient.Set(store.options.KeyPrefix+session.id, data, ttl).Err(); err != nil {
+		return fmt.Errorf("redis.Set session err %v", err)
+	}
+	if session.regenerated != "" {
+		redisClient.Del(store.options.KeyPrefix + session.regenerated)
+	}
+	if created {
+		store.setCookie(ctx, store.sign(session.id), int(session.CreatedAt().Add(store.options.AbsoluteTimeout).Sub(store.now()).Seconds()))
+	}
+	return nil
+}
+
+func (store *sessionStore) setCookie(ctx *gin.Context, value string, maxAge int) {
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     store.options.CookieName,
+		Value:    value,
+		Path:     store.options.Path,
+		Domain:   store.options.Domain,
+		MaxAge:   maxAge,
+		Secure:   store.options.Secure,
+		HttpOnly: true,
+		SameSite: store.options.SameSite,
+	})
+}
+
+// Cookie value "<id>.<signature>"
+func (store *sessionStore) sign(id string) string {
+	mac := hmac.New(sha256.New, store.options.Secret)
+	mac.Write([]byte(id))
+	return id + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (store *sessionStore) verify(cookie string) (string, bool) {
+	id, _, ok := strings.Cut(cookie, ".")
+	if !ok || id == "" {
+		return "", false
+	}
+	return id, hmac.Equal([]byte(store.sign(id)), []byte(cookie))
+}
+
+func newSessionID() (string, error) {
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("rand.Read err %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(id), nil
+}
+
+// Saves the session when the handler starts writing its response
+type sessionWriter struct {
+	gin.ResponseWriter
+	commit    func()
+	committed bool
+}
+
+func (writer *sessionWriter) commitOnce() {
+	if !writer.committed {
+		writer.committed = true
+		writer.commit()
+	}
+}
+
+func (writer *sessionWriter) WriteHeader(code int) {
+	writer.commitOnce()
+	writer.ResponseWriter.WriteHeader(code)
+}
+
+func (writer *sessionWriter) WriteHeaderNow() {
+	writer.commitOnce()
+	writer.ResponseWriter.WriteHeaderNow()
+}
+
+func (writer *sessionWriter) Write(data []byte) (int, error) {
+	writer.commitOnce()
+	return writer.ResponseWriter.Write(data)
+}
+
+func (writer *sessionWriter) WriteString(s string) (int, error) {
+	writer.commitOnce()
+	return writer.ResponseWriter.WriteString(s)
+}
+
+// Session of the request, nil without SessionMiddleware
+func (r *Router) Session() *Session {
+	value, ok := r.ApiContext.Get(sessionContextKey)
+	if !ok {
+		return nil
+	}
+	session, _ := value.(*Session)
+	return session
+}
+
+// Decode the session value of key into v, returns false when it is not set or there is no session
+func (r *Router) SessionGet(key string, v interface{}) bool {
+	session := r.Session()
+	if session == nil {
+		return false
+	}
+	ok, err := session.Get(key, v)
+	return ok && err == nil
+}
+
+func (r *Router) SessionSet(key string, v interface{}) error {
+	session := r.Session()
+	if session == nil {
+		return fmt.Errorf("no session, SessionMiddleware is not installed")
+	}
+	return session.Set(key, v)
+}
+
+// Typed session value of key, the zero value and false when it is not set
+func SessionValue[T any](r *Router, key string) (T, bool) {
+	var value T
+	ok := r.SessionGet(key, &value)
+	return value, ok
+}
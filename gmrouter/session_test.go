@@ -0,0 +1,160 @@
+package gmrouter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gm "github.com/W3Tools/go-modules"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+)
+
+type testSessionClient struct {
+	engine *gin.Engine
+	cookie *http.Cookie
+}
+
+func (client *testSessionClient) get(t *testing.T, path string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if client.cookie != nil {
+		req.AddCookie(client.cookie)
+	}
+	rsp := httptest.NewRecorder()
+	client.engine.ServeHTTP(rsp, req)
+
+	for _, cookie := range rsp.Result().Cookies() {
+		if cookie.Name == "session" {
+			client.cookie = cookie
+			if cookie.MaxAge < 0 {
+				client.cookie = nil
+			}
+		}
+	}
+	return rsp
+}
+
+func newTestSessionEngine(t *testing.T, now func() time.Time) (*gin.Engine, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	redisClient, err := gm.InitRedisFromURL(context.Background(), "redis://"+server.Addr())
+	if err != nil {
+		t.Fatalf("InitRedisFromURL err, msg: %v", err)
+	}
+	t.Cleanup(func() { redisClient.Close() })
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(newSessionStore(SessionOptions{
+		RedisClient:     redisClient,
+		Secret:          []byte("secret"),
+		IdleTimeout:     10 * time.Minute,
+		AbsoluteTimeout: time.Hour,
+	}, now).handle)
+
+	engine.GET("/login", func(ctx *gin.Context) {
+		r := Router{ApiContext: ctx}
+		r.Session().Regenerate()
+		r.SessionSet("userId", 42)
+		r.Session().AddFlash("welcome")
+		r.ApiResponseOk(r.Session().ID())
+	})
+	engine.GET("/me", func(ctx *gin.Context) {
+		r := Router{ApiContext: ctx}
+		userId, ok := SessionValue[int](&r, "userId")
+		if !ok {
+			r.ApiResponseUnauthorized()
+			return
+		}
+		r.ApiResponseOk(map[string]interface{}{"userId": userId, "flashes": r.Session().Flashes()})
+	})
+	engine.GET("/logout", func(ctx *gin.Context) {
+		r := Router{ApiContext: ctx}
+		r.Session().Destroy()
+		r.ApiResponseOk(nil)
+	})
+	return engine, server
+}
+
+func TestSession(t *testing.T) {
+	engine, server := newTestSessionEngine(t, time.Now)
+	client := &testSessionClient{engine: engine}
+
+	if rsp := client.get(t, "/me"); rsp.Code != http.StatusUnauthorized || client.cookie != nil {
+		t.Fatalf("expected an anonymous request without cookie, but got %d %v", rsp.Code, client.cookie)
+	}
+	if keys := server.Keys(); len(keys) != 0 {
+		t.Errorf("expected no session for anonymous requests, but got %v", keys)
+	}
+
+	client.get(t, "/login")
+	if client.cookie == nil || !client.cookie.HttpOnly || client.cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("expected an HttpOnly SameSite cookie, but got %v", client.cookie)
+	}
+	first := client.cookie
+
+	rsp := client.get(t, "/me")
+	if !strings.Contains(rsp.Body.String(), `"userId":42`) || !strings.Contains(rsp.Body.String(), `"flashes":["welcome"]`) {
+		t.Errorf("unexpected response %s", rsp.Body.String())
+	}
+	if rsp := client.get(t, "/me"); !strings.Contains(rsp.Body.String(), `"flashes":null`) {
+		t.Errorf("expected the flash to be shown once, but got %s", rsp.Body.String())
+	}
+
+	// A new login moves the session to a new id and drops the old one
+	client.get(t, "/login")
+	if client.cookie.Value == first.Value {
+		t.Error("expected a new session id on login")
+	}
+	if keys := server.Keys(); len(keys) != 1 {
+		t.Errorf("expected the old session to be deleted, but got %v", keys)
+	}
+	old := &testSessionClient{engine: engine, cookie: first}
+	if rsp := old.get(t, "/me"); rsp.Code != http.StatusUnauthorized {
+		t.Errorf("expected the old session id to be rejected, but got %d", rsp.Code)
+	}
+
+	// A tampered cookie is ignored
+	id, _, _ := strings.Cut(client.cookie.Value, ".")
+	forged := &testSessionClient{engine: engine, cookie: &http.Cookie{Name: "session", Value: id + ".forged"}}
+	if rsp := forged.get(t, "/me"); rsp.Code != http.StatusUnauthorized {
+		t.Errorf("expected a forged cookie to be rejected, but got %d", rsp.Code)
+	}
+
+	client.get(t, "/logout")
+	if client.cookie != nil || len(server.Keys()) != 0 {
+		t.Errorf("expected logout to delete the session, but got %v %v", client.cookie, server.Keys())
+	}
+}
+
+func TestSessionTimeouts(t *testing.T) {
+	now := time.Now()
+	engine, server := newTestSessionEngine(t, func() time.Time { return now })
+	client := &testSessionClient{engine: engine}
+
+	// Requests slide the idle timeout
+	client.get(t, "/login")
+	for i := 0; i < 3; i++ {
+		server.FastForward(9 * time.Minute)
+		if rsp := client.get(t, "/me"); rsp.Code != http.StatusOK {
+			t.Fatalf("expected the session to stay alive, but got %d", rsp.Code)
+		}
+	}
+	server.FastForward(11 * time.Minute)
+	if rsp := client.get(t, "/me"); rsp.Code != http.StatusUnauthorized {
+		t.Errorf("expected the idle session to expire, but got %d", rsp.Code)
+	}
+
+	// Activity does not extend the session past the absolute timeout
+	client.get(t, "/login")
+	now = now.Add(61 * time.Minute)
+	if rsp := client.get(t, "/me"); rsp.Code != http.StatusUnauthorized {
+		t.Errorf("expected the session to expire after the absolute timeout, but got %d", rsp.Code)
+	}
+}
@@ -0,0 +1,360 @@
+package gm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+const DefaultGormName = "default"
+
+type GormDriver string
+
+const (
+	GormMySQL    GormDriver = "mysql"
+	GormPostgres GormDriver = "postgres"
+	GormSQLite   GormDriver = "sqlite" // pure Go, no cgo needed
+)
+
+// How reads pick one of the replicas
+type GormReplicaPolicy string
+
+const (
+	GormReplicaRandom     GormReplicaPolicy = "random"
+	GormReplicaRoundRobin GormReplicaPolicy = "round_robin"
+)
+
+type gormPrimaryKey struct{}
+
+var (
+	gormClientsMu sync.RWMutex
+	gormClients   = map[string]*GormClient{}
+
+	defaultGormPingRetries  = 3
+	defaultGormPingInterval = time.Second
+)
+
+type GormOptions struct {
+	Name   string     // registry name, see Gorm, "default" when empty
+	Driver GormDriver // GormMySQL when empty
+
+	Host     string
+	Port     int64 // 3306 for MySQL and 5432 for PostgreSQL when zero
+	User     string
+	Password string
+	DBName   string            // database, or file path for SQLite, ":memory:" for an in-memory database
+	Params   map[string]string // DSN parameters, merged over the defaults of the driver
+	DSN      string            // complete DSN of the driver, replaces the fields above
+
+	Replicas      []string          // DSNs of read replicas, queries go to them while writes and transactions stay on the primary
+	ReplicaPolicy GormReplicaPolicy // GormReplicaRandom when empty
+
+	MaxOpenConns    int           // unlimited when zero
+	MaxIdleConns    int           // 2 when zero
+	ConnMaxLifetime time.Duration // connections are reused forever when zero
+	ConnMaxIdleTime time.Duration // idle connections are kept forever when zero
+
+	Config *gorm.Config // logger, naming strategy, PrepareStmt... default config when nil
+
+	PingRetries  int           // connection attempts after the first one, 3 when zero, negative fails at once
+	PingInterval time.Duration // delay between the connection attempts, 1 second when zero
+}
+
+// A database opened by InitGormWithOptions, safe for concurrent use
+type GormClient struct {
+	Name     string
+	db       *gorm.DB
+	replicas []gorm.ConnPool // pools dbresolver opened for the replicas, closed with the primary
+}
+
+/*
+Open a database and register it under options.Name, a name can only be registered once.
+The connection is retried while the database is starting, the "default" database backs NewGormSession and BeginGormTx.
+
+	orders, err := gm.InitGormWithOptions(ctx, &gm.GormOptions{
+		Name:            "orders",
+		Host:            "127.0.0.1",
+		User:            "root",
+		Password:        password,
+		DBName:          "orders",
+		Params:          map[string]string{"loc": "UTC", "timeout": "5s"},
+		MaxOpenConns:    50,
+		MaxIdleConns:    10,
+		ConnMaxLifetime: time.Hour,
+		Config:          &gorm.Config{PrepareStmt: true, Logger: logger.Default.LogMode(logger.Warn)},
+	})
+
+	gm.Gorm("orders").Session().Find(&rows)
+*/
+func InitGormWithOptions(ctx context.Context, options *GormOptions) (*GormClient, error) {
+	if options == nil {
+		options = &GormOptions{}
+	}
+	name := options.Name
+	if name == "" {
+		name = DefaultGormName
+	}
+	if Gorm(name) != nil {
+		return nil, fmt.Errorf("gorm database %s is already initialized", name)
+	}
+
+	db, replicas, err := openGorm(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &GormClient{Name: name, db: db, replicas: replicas}
+	if err := registerGorm(client); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// Open the primary and the pools of the replicas
+func openGorm(ctx context.Context, options *GormOptions) (*gorm.DB, []gorm.ConnPool, error) {
+	config := options.Config
+	if config == nil {
+		config = &gorm.Config{}
+	}
+
+	dsn, err := gormDSN(options)
+	if err != nil {
+		return nil, nil, err
+	}
+	dialector, err := gormDialector(options.Driver, dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := openWithRetry(ctx, dialector, config, options.PingRetries, options.PingInterval)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(options.MaxOpenConns)
+	if options.MaxIdleConns != 0 {
+		sqlDB.SetMaxIdleConns(options.MaxIdleConns)
+	}
+	sqlDB.SetConnMaxLifetime(options.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(options.ConnMaxIdleTime)
+
+	// Every connection to ":memory:" opens its own empty database, the only one must stay open
+	if options.Driver == GormSQLite && isSQLiteMemory(dsn) {
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+	}
+
+	if len(options.Replicas) == 0 {
+		return db, nil, nil
+	}
+
+	var pools []gorm.ConnPool
+	replicas := make([]gorm.Dialector, 0, len(options.Replicas))
+	for _, dsn := range options.Replicas {
+		replica, err := gormDialector(options.Driver, dsn)
+		if err != nil {
+			sqlDB.Close()
+			return nil, nil, err
+		}
+		replicas = append(replicas, &gormReplicaDialector{Dialector: replica, pools: &pools})
+	}
+	var policy dbresolver.Policy = dbresolver.RandomPolicy{}
+	if options.ReplicaPolicy == GormReplicaRoundRobin {
+		policy = &roundRobinPolicy{}
+	}
+
+	resolver := dbresolver.Register(dbresolver.Config{Replicas: replicas, Policy: policy}).
+		SetMaxOpenConns(options.MaxOpenConns).
+		SetConnMaxLifetime(options.ConnMaxLifetime).
+		SetConnMaxIdleTime(options.ConnMaxIdleTime)
+	if options.MaxIdleConns != 0 {
+		resolver.SetMaxIdleConns(options.MaxIdleConns)
+	}
+	if err := db.Use(resolver); err != nil {
+		closeGormPools(pools)
+		sqlDB.Close()
+		return nil, nil, fmt.Errorf("gorm.Use replicas err %v", err)
+	}
+	return db, pools, nil
+}
+
+// Replica dialector recording the pool dbresolver opens with it, dbresolver itself never closes them
+type gormReplicaDialector struct {
+	gorm.Dialector
+	pools *[]gorm.ConnPool
+}
+
+func (dialector *gormReplicaDialector) Initialize(db *gorm.DB) error {
+	if err := dialector.Dialector.Initialize(db); err != nil {
+		return err
+	}
+	*dialector.pools = append(*dialector.pools, db.ConnPool)
+	return nil
+}
+
+func closeGormPools(pools []gorm.ConnPool) {
+	for _, pool := range pools {
+		if closer, ok := pool.(interface{ Close() error }); ok {
+			closer.Close()
+		}
+	}
+}
+
+func gormDSN(options *GormOptions) (string, error) {
+	if options.DSN != "" {
+		return options.DSN, nil
+	}
+
+	switch options.Driver {
+	case "", GormMySQL:
+		return mysqlDSN(options), nil
+	case GormPostgres:
+		return postgresDSN(options), nil
+	case GormSQLite:
+		return sqliteDSN(options), nil
+	}
+	return "", fmt.Errorf("unsupported gorm driver %s", options.Driver)
+}
+
+func gormDialector(driver GormDriver, dsn string) (gorm.Dialector, error) {
+	switch driver {
+	case "", GormMySQL:
+		return mysqlDialector(dsn), nil
+	case GormPostgres:
+		return postgresDialector(dsn), nil
+	case GormSQLite:
+		return sqliteDialector(dsn), nil
+	}
+	return nil, fmt.Errorf("unsupported gorm driver %s", driver)
+}
+
+type roundRobinPolicy struct {
+	next atomic.Uint64
+}
+
+func (policy *roundRobinPolicy) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	return connPools[(policy.next.Add(1)-1)%uint64(len(connPools))]
+}
+
+// Open and ping, retries times with interval between the attempts
+func openWithRetry(ctx context.Context, dialector gorm.Dialector, config *gorm.Config, retries int, interval time.Duration) (*gorm.DB, error) {
+	if retries == 0 {
+		retries = defaultGormPingRetries
+	}
+	if interval <= 0 {
+		interval = defaultGormPingInterval
+	}
+
+	var err error
+	for attempt := 0; attempt <= max(retries, 0); attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(interval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, fmt.Errorf("gorm.Open err %v: %w", err, ctx.Err())
+			case <-timer.C:
+			}
+		}
+
+		var db *gorm.DB
+		if db, err = gorm.Open(dialector, config); err != nil {
+			continue
+		}
+		var sqlDB *sql.DB
+		if sqlDB, err = db.DB(); err != nil {
+			continue
+		}
+		if err = sqlDB.PingContext(ctx); err != nil {
+			sqlDB.Close()
+			continue
+		}
+		return db, nil
+	}
+	return nil, fmt.Errorf("gorm.Open err, msg: %v", err)
+}
+
+// Database registered under name, nil when there is none
+func Gorm(name string) *GormClient {
+	gormClientsMu.RLock()
+	defer gormClientsMu.RUnlock()
+	return gormClients[name]
+}
+
+func registerGorm(client *GormClient) error {
+	gormClientsMu.Lock()
+	defer gormClientsMu.Unlock()
+
+	if _, ok := gormClients[client.Name]; ok {
+		return fmt.Errorf("gorm database %s is already initialized", client.Name)
+	}
+	gormClients[client.Name] = client
+
+	if client.Name == DefaultGormName && gormDB == nil {
+		gormDB = client.db
+	}
+	return nil
+}
+
+func (client *GormClient) DB() *gorm.DB {
+	return client.db
+}
+
+func (client *GormClient) Session() *gorm.DB {
+	return client.db.Session(&gorm.Session{})
+}
+
+func (client *GormClient) Begin() *gorm.DB {
+	return client.db.Begin()
+}
+
+// Run the queries on the primary, to read what was just written without the replication delay
+func (client *GormClient) Primary() *gorm.DB {
+	return client.db.Clauses(dbresolver.Write)
+}
+
+/*
+Mark ctx so the queries of GormClient.WithContext run on the primary,
+e.g. in a middleware after a request wrote data the rest of the request reads again
+*/
+func WithGormPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, gormPrimaryKey{}, true)
+}
+
+// Session bound to ctx, on the primary when ctx is marked by WithGormPrimary
+func (client *GormClient) WithContext(ctx context.Context) *gorm.DB {
+	db := client.db.WithContext(ctx)
+	if primary, _ := ctx.Value(gormPrimaryKey{}).(bool); primary {
+		db = db.Clauses(dbresolver.Write)
+	}
+	return db
+}
+
+// Close the connection pools of the primary and the replicas, the name can be registered again
+func (client *GormClient) Close() error {
+	gormClientsMu.Lock()
+	if gormClients[client.Name] == client {
+		delete(gormClients, client.Name)
+		if gormDB == client.db {
+			gormDB = nil
+		}
+	}
+	gormClientsMu.Unlock()
+
+	closeGormPools(client.replicas)
+
+	sqlDB, err := client.db.DB()
+	if err != nil {
+		return fmt.Errorf("gorm.DB err %v", err)
+	}
+	return sqlDB.Close()
+}
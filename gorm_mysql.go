@@ -1,7 +1,9 @@
 package gm
 
 import (
+	"context"
 	"fmt"
+	"net/url"
 
 	_ "github.com/go-sql-driver/mysql"
 	"gorm.io/driver/mysql"
@@ -12,33 +14,64 @@ var (
 	gormDB *gorm.DB
 )
 
+var defaultMysqlParams = map[string]string{
+	"charset":   "utf8mb4",
+	"parseTime": "True",
+	"loc":       "Local",
+}
+
+// Connect the "default" database, an error is returned when it is already initialized
 func InitGorm(host string, port int64, user, password, db_name string) (err error) {
-	var dsn = fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		user, password, host, port, db_name,
-	)
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
-	if err != nil {
-		return fmt.Errorf("gorm.Open err, msg: %v", err)
+	_, err = InitGormWithOptions(context.Background(), &GormOptions{
+		Host:        host,
+		Port:        port,
+		User:        user,
+		Password:    password,
+		DBName:      db_name,
+		PingRetries: -1,
+	})
+	return
+}
+
+func mysqlDialector(dsn string) gorm.Dialector {
+	return mysql.Open(dsn)
+}
+
+func mysqlDSN(options *GormOptions) string {
+	if options.DSN != "" {
+		return options.DSN
 	}
 
-	if gormDB == nil {
-		gormDB = db
+	port := options.Port
+	if port == 0 {
+		port = 3306
 	}
 
-	return
+	params := url.Values{}
+	for key, value := range defaultMysqlParams {
+		params.Set(key, value)
+	}
+	for key, value := range options.Params {
+		params.Set(key, value)
+	}
+
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?%s", options.User, options.Password, options.Host, port, options.DBName, params.Encode())
 }
 
 func NewGormSession() *gorm.DB {
 	return gormDB.Session(&gorm.Session{})
 }
 
+// Deprecated: use WithGormTx or GormClient.WithTx, they roll back on errors and panics.
 func BeginGormTx() *gorm.DB {
 	return gormDB.Begin()
 }
 
+// Commit a transaction of BeginGormTx, a failed commit is not rolled back again, the driver already ended it.
+//
+// Deprecated: use WithGormTx or GormClient.WithTx.
 func CommitGormTx(tx *gorm.DB) error {
 	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
 		return fmt.Errorf("tx.Commit %v", err)
 	}
 	return nil
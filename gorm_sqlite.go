@@ -0,0 +1,37 @@
+package gm
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+var defaultSqliteParams = map[string]string{
+	"_pragma": "foreign_keys(1)",
+}
+
+/*
+File path of DBName with its parameters, an in-memory database for tests:
+
+	client, err := gm.InitGormWithOptions(ctx, &gm.GormOptions{Driver: gm.GormSQLite, DBName: ":memory:"})
+*/
+func sqliteDSN(options *GormOptions) string {
+	params := url.Values{}
+	for key, value := range defaultSqliteParams {
+		params.Set(key, value)
+	}
+	for key, value := range options.Params {
+		params.Set(key, value)
+	}
+	return options.DBName + "?" + params.Encode()
+}
+
+func isSQLiteMemory(dsn string) bool {
+	return strings.HasPrefix(dsn, ":memory:") || strings.Contains(dsn, "mode=memory")
+}
+
+func sqliteDialector(dsn string) gorm.Dialector {
+	return sqlite.Open(dsn)
+}
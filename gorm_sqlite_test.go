@@ -0,0 +1,167 @@
+package gm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+type testAccount struct {
+	ID      int64
+	Name    string
+	Balance int64
+}
+
+func newTestSQLiteClient(t *testing.T, options *GormOptions) *GormClient {
+	t.Helper()
+
+	options.Name = t.Name() + "/" + options.Name
+	options.Driver = GormSQLite
+	client, err := InitGormWithOptions(context.Background(), options)
+	if err != nil {
+		t.Fatalf("InitGormWithOptions err, msg: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestGormSQLiteMemory(t *testing.T) {
+	client := newTestSQLiteClient(t, &GormOptions{DBName: ":memory:"})
+	ctx := context.Background()
+
+	if err := client.DB().AutoMigrate(&testAccount{}); err != nil {
+		t.Fatalf("AutoMigrate err, msg: %v", err)
+	}
+	client.Session().Create(&testAccount{Name: "alice", Balance: 100})
+
+	// The savepoint of the failed transfer is rolled back, the outer transaction commits
+	err := client.WithTx(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		tx.Create(&testAccount{Name: "bob", Balance: 0})
+
+		transfer := client.WithTx(ctx, func(ctx context.Context, tx *gorm.DB) error {
+			client.FromContext(ctx).Model(&testAccount{}).Where("name = ?", "alice").Update("balance", gorm.Expr("balance - ?", 500))
+			return errors.New("insufficient balance")
+		})
+		if transfer == nil {
+			t.Error("expected the transfer to fail")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx err, msg: %v", err)
+	}
+
+	var accounts []testAccount
+	client.Session().Order("id").Find(&accounts)
+	if len(accounts) != 2 || accounts[0].Balance != 100 || accounts[1].Name != "bob" {
+		t.Errorf("unexpected accounts %+v", accounts)
+	}
+
+	// Each test gets its own database
+	other := newTestSQLiteClient(t, &GormOptions{Name: "other", DBName: ":memory:"})
+	if other.DB().Migrator().HasTable(&testAccount{}) {
+		t.Error("expected a separate in-memory database")
+	}
+}
+
+func TestGormSQLiteTxPerClient(t *testing.T) {
+	orders := newTestSQLiteClient(t, &GormOptions{Name: "orders", DBName: filepath.Join(t.TempDir(), "orders.db")})
+	reports := newTestSQLiteClient(t, &GormOptions{Name: "reports", DBName: filepath.Join(t.TempDir(), "reports.db")})
+	orders.DB().AutoMigrate(&testAccount{})
+	reports.DB().AutoMigrate(&testAccount{})
+
+	// The reports transaction commits on its own database even though the orders one rolls back
+	err := orders.WithTx(context.Background(), func(ctx context.Context, tx *gorm.DB) error {
+		tx.Create(&testAccount{Name: "order"})
+
+		err := reports.WithTx(ctx, func(ctx context.Context, tx *gorm.DB) error {
+			if reports.FromContext(ctx).Statement.ConnPool == orders.FromContext(ctx).Statement.ConnPool {
+				t.Error("expected each client to have its own transaction")
+			}
+			return reports.FromContext(ctx).Create(&testAccount{Name: "report"}).Error
+		})
+		if err != nil {
+			t.Errorf("reports WithTx err, msg: %v", err)
+		}
+		return errors.New("cancel the order")
+	})
+	if err == nil {
+		t.Fatal("expected the orders transaction to fail")
+	}
+
+	var count int64
+	orders.Session().Model(&testAccount{}).Count(&count)
+	if count != 0 {
+		t.Errorf("expected the order to be rolled back and no report on orders, but got %d rows", count)
+	}
+	var report testAccount
+	reports.Session().First(&report)
+	if report.Name != "report" {
+		t.Errorf("expected the report on the reports database, but got %+v", report)
+	}
+}
+
+func TestGormSQLiteReplicas(t *testing.T) {
+	dir := t.TempDir()
+	primaryFile, replicaFile := filepath.Join(dir, "primary.db"), filepath.Join(dir, "replica.db")
+
+	// The replica lags behind: it has the table but not the rows of the primary
+	replica := newTestSQLiteClient(t, &GormOptions{Name: "replica", DBName: replicaFile})
+	replica.DB().AutoMigrate(&testAccount{})
+	replica.Session().Create(&testAccount{Name: "stale"})
+
+	client := newTestSQLiteClient(t, &GormOptions{DBName: primaryFile, Replicas: []string{sqliteDSN(&GormOptions{DBName: replicaFile})}, ReplicaPolicy: GormReplicaRoundRobin})
+	client.Primary().AutoMigrate(&testAccount{})
+	client.Session().Create(&testAccount{Name: "fresh"})
+
+	var account testAccount
+	client.Session().First(&account)
+	if account.Name != "stale" {
+		t.Errorf("expected the read to go to the replica, but got %s", account.Name)
+	}
+
+	account = testAccount{}
+	client.WithContext(WithGormPrimary(context.Background())).First(&account)
+	if account.Name != "fresh" {
+		t.Errorf("expected the forced read to go to the primary, but got %s", account.Name)
+	}
+
+	account = testAccount{}
+	client.WithTx(context.Background(), func(ctx context.Context, tx *gorm.DB) error {
+		return tx.First(&account).Error
+	})
+	if account.Name != "fresh" {
+		t.Errorf("expected transactions to use the primary, but got %s", account.Name)
+	}
+	// dbresolver never closes the pools it opens, Close has to
+	if len(client.replicas) != 1 {
+		t.Fatalf("expected the replica pool to be kept, but got %d", len(client.replicas))
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close err, msg: %v", err)
+	}
+	if err := client.replicas[0].(*sql.DB).Ping(); err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Errorf("expected the replica pool to be closed, but got %v", err)
+	}
+}
+
+func TestGormDrivers(t *testing.T) {
+	dsn := postgresDSN(&GormOptions{Host: "db", User: "app", Password: "p@ss word", DBName: "orders", Params: map[string]string{"sslmode": "disable"}})
+	if expected := "postgres://app:p%40ss%20word@db:5432/orders?sslmode=disable"; dsn != expected {
+		t.Errorf("expected %s, but got %s", expected, dsn)
+	}
+
+	if _, err := InitGormWithOptions(context.Background(), &GormOptions{Name: "oracle", Driver: "oracle"}); err == nil {
+		t.Error("expected an unsupported driver to be rejected")
+	}
+
+	if !isRetryableTxError(&pgconn.PgError{Code: postgresErrDeadlock}) || isRetryableTxError(&pgconn.PgError{Code: "23505"}) {
+		t.Error("expected only deadlocks and serialization failures to be retried")
+	}
+}
@@ -0,0 +1,91 @@
+package gm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestMysqlDSN(t *testing.T) {
+	dsn := mysqlDSN(&GormOptions{Host: "db", User: "root", Password: "pw", DBName: "app", Params: map[string]string{"loc": "Asia/Shanghai", "timeout": "5s"}})
+	expected := "root:pw@tcp(db:3306)/app?charset=utf8mb4&loc=Asia%2FShanghai&parseTime=True&timeout=5s"
+	if dsn != expected {
+		t.Errorf("expected %s, but got %s", expected, dsn)
+	}
+
+	if dsn := mysqlDSN(&GormOptions{DSN: "custom", Host: "ignored"}); dsn != "custom" {
+		t.Errorf("expected the DSN to be used as is, but got %s", dsn)
+	}
+}
+
+func TestOpenGormRetry(t *testing.T) {
+	options := &GormOptions{Host: "127.0.0.1", Port: 1, PingRetries: 2, PingInterval: 50 * time.Millisecond, Params: map[string]string{"timeout": "100ms"}}
+
+	start := time.Now()
+	if _, err := InitGormWithOptions(context.Background(), options); err == nil {
+		t.Fatal("expected an error without a database")
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected 2 retries 50ms apart, but gave up after %s", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	options.PingInterval = time.Minute
+	if _, err := InitGormWithOptions(ctx, options); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, but got %v", err)
+	}
+}
+
+func TestGormRegistry(t *testing.T) {
+	// A pool that connects lazily, nothing is sent to the server
+	sqlDB, _ := sql.Open("mysql", "root@tcp(127.0.0.1:1)/app")
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("gorm.Open err, msg: %v", err)
+	}
+
+	client := &GormClient{Name: "reports", db: db}
+	if err := registerGorm(client); err != nil {
+		t.Fatalf("registerGorm err, msg: %v", err)
+	}
+	if Gorm("reports") != client || Gorm("missing") != nil {
+		t.Error("expected the client to be registered under its name")
+	}
+	if _, err := InitGormWithOptions(context.Background(), &GormOptions{Name: "reports"}); err == nil || !strings.Contains(err.Error(), "already initialized") {
+		t.Errorf("expected a duplicate name to be rejected, but got %v", err)
+	}
+
+	client.Close()
+	if Gorm("reports") != nil {
+		t.Error("expected Close to unregister the client")
+	}
+}
+
+func TestGormReplicaRouting(t *testing.T) {
+	policy := &roundRobinPolicy{}
+	pools := []gorm.ConnPool{&sql.DB{}, &sql.DB{}, &sql.DB{}}
+	for i := 0; i < 6; i++ {
+		if policy.Resolve(pools) != pools[i%3] {
+			t.Errorf("expected replica %d on call %d", i%3, i)
+		}
+	}
+
+	sqlDB, _ := sql.Open("mysql", "root@tcp(127.0.0.1:1)/app")
+	db, _ := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{DisableAutomaticPing: true})
+	client := &GormClient{Name: "routing", db: db}
+
+	forced := func(db *gorm.DB) bool {
+		_, ok := db.Statement.Settings.Load("gorm:db_resolver:write")
+		return ok
+	}
+	if forced(client.WithContext(context.Background())) || !forced(client.WithContext(WithGormPrimary(context.Background()))) || !forced(client.Primary()) {
+		t.Error("expected only Primary and WithGormPrimary contexts to force the primary")
+	}
+}
@@ -0,0 +1,180 @@
+package gm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	mathrand "math/rand/v2"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+var (
+	defaultTxMaxAttempts = 3
+	defaultTxMinBackoff  = 20 * time.Millisecond
+	defaultTxMaxBackoff  = 500 * time.Millisecond
+)
+
+// Errors that abort a transaction which succeeds when run again
+const (
+	mysqlErrLockWaitTimeout = 1205
+	mysqlErrDeadlock        = 1213
+
+	postgresErrSerialization = "40001"
+	postgresErrDeadlock      = "40P01"
+)
+
+type GormTxOptions struct {
+	MaxAttempts int            // runs of fn when the database reports a deadlock, lock wait timeout or serialization failure, 3 when zero
+	MinBackoff  time.Duration  // delay before the second run, doubled for every further run, 20 milliseconds when zero
+	MaxBackoff  time.Duration  // retry delay cap, 500 milliseconds when zero
+	TxOptions   *sql.TxOptions // isolation level and read only mode
+}
+
+func (options *GormTxOptions) withDefaults() GormTxOptions {
+	opts := GormTxOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultTxMaxAttempts
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = defaultTxMinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(defaultTxMaxBackoff, opts.MinBackoff)
+	}
+	return opts
+}
+
+// Context key of the transaction of one database, so WithTx on another client starts its own transaction
+type gormTxKey struct {
+	db *gorm.DB
+}
+
+// Transaction carried by the context of WithTx, depth counts the nested savepoints
+type gormTxState struct {
+	tx    *gorm.DB
+	depth int
+}
+
+/*
+Run fn in a transaction, committed when fn returns nil and rolled back when it returns an error or panics.
+ctx passed to fn carries the transaction: a WithTx of the same client inside fn runs in a savepoint of it, see FromContext for the queries.
+WithTx of another client inside fn runs its own transaction, committed independently.
+
+	err := gm.Gorm("orders").WithTx(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		return inventory.Reserve(ctx, order.Items) // uses gm.Gorm("orders").FromContext(ctx)
+	})
+*/
+func (client *GormClient) WithTx(ctx context.Context, fn func(ctx context.Context, tx *gorm.DB) error) error {
+	return client.WithTxOptions(ctx, nil, fn)
+}
+
+// WithTx with retry and isolation options, nested calls run once in their savepoint and leave the retry to the outermost one
+func (client *GormClient) WithTxOptions(ctx context.Context, options *GormTxOptions, fn func(ctx context.Context, tx *gorm.DB) error) error {
+	if state, ok := ctx.Value(gormTxKey{db: client.db}).(*gormTxState); ok {
+		return client.withSavepoint(ctx, state, fn)
+	}
+
+	opts := options.withDefaults()
+	backoff := opts.MinBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := client.runTx(ctx, opts.TxOptions, fn)
+		if err == nil || !isRetryableTxError(err) || attempt >= opts.MaxAttempts {
+			return err
+		}
+
+		timer := time.NewTimer(time.Duration(mathrand.Int64N(int64(backoff)) + 1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w: %w", err, ctx.Err())
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, opts.MaxBackoff)
+	}
+}
+
+func (client *GormClient) runTx(ctx context.Context, txOptions *sql.TxOptions, fn func(ctx context.Context, tx *gorm.DB) error) error {
+	tx := client.db.WithContext(ctx).Begin(txOptions)
+	if tx.Error != nil {
+		return fmt.Errorf("gorm.Begin err %w", tx.Error)
+	}
+
+	// Also runs while a panic of fn unwinds
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	txCtx := context.WithValue(ctx, gormTxKey{db: client.db}, &gormTxState{tx: tx})
+	if err := fn(txCtx, tx.WithContext(txCtx)); err != nil {
+		return err
+	}
+
+	committed = true
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("gorm.Commit err %w", err)
+	}
+	return nil
+}
+
+func (client *GormClient) withSavepoint(ctx context.Context, state *gormTxState, fn func(ctx context.Context, tx *gorm.DB) error) error {
+	nested := &gormTxState{tx: state.tx, depth: state.depth + 1}
+	name := fmt.Sprintf("gm_sp_%d", nested.depth)
+
+	if err := state.tx.SavePoint(name).Error; err != nil {
+		return fmt.Errorf("gorm.SavePoint err %w", err)
+	}
+
+	released := false
+	defer func() {
+		if !released {
+			state.tx.RollbackTo(name)
+		}
+	}()
+
+	txCtx := context.WithValue(ctx, gormTxKey{db: client.db}, nested)
+	if err := fn(txCtx, state.tx.WithContext(txCtx)); err != nil {
+		return err
+	}
+	released = true
+	return nil
+}
+
+// Transaction of ctx when it comes from WithTx of this client, otherwise a session of the client bound to ctx
+func (client *GormClient) FromContext(ctx context.Context) *gorm.DB {
+	if state, ok := ctx.Value(gormTxKey{db: client.db}).(*gormTxState); ok {
+		return state.tx.WithContext(ctx)
+	}
+	return client.WithContext(ctx)
+}
+
+// WithTx on the database of InitGorm, shares the transaction of ctx with gm.Gorm("default")
+func WithGormTx(ctx context.Context, fn func(ctx context.Context, tx *gorm.DB) error) error {
+	return (&GormClient{Name: DefaultGormName, db: gormDB}).WithTx(ctx, fn)
+}
+
+func isRetryableTxError(err error) bool {
+	var mysqlErr *mysqldriver.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlErrDeadlock || mysqlErr.Number == mysqlErrLockWaitTimeout
+	}
+	var postgresErr *pgconn.PgError
+	if errors.As(err, &postgresErr) {
+		return postgresErr.Code == postgresErrDeadlock || postgresErr.Code == postgresErrSerialization
+	}
+	return false
+}
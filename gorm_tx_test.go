@@ -0,0 +1,174 @@
+package gm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// Records the statements it receives, statements listed in failures fail once with their error
+type recordingDriver struct {
+	mu         sync.Mutex
+	statements []string
+	failures   map[string]error
+}
+
+func (d *recordingDriver) Open(string) (driver.Conn, error) { return &recordingConn{d}, nil }
+
+func (d *recordingDriver) record(query string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.statements = append(d.statements, query)
+	if err, ok := d.failures[query]; ok {
+		delete(d.failures, query)
+		return err
+	}
+	return nil
+}
+
+func (d *recordingDriver) log() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	log := strings.Join(d.statements, "; ")
+	d.statements = nil
+	return log
+}
+
+type recordingConn struct{ driver *recordingDriver }
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c *recordingConn) Close() error { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	return &recordingTx{c.driver}, c.driver.record("BEGIN")
+}
+func (c *recordingConn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), c.driver.record(query)
+}
+
+type recordingTx struct{ driver *recordingDriver }
+
+func (tx *recordingTx) Commit() error   { return tx.driver.record("COMMIT") }
+func (tx *recordingTx) Rollback() error { return tx.driver.record("ROLLBACK") }
+
+var registerRecordingDriver sync.Once
+
+func newRecordingGormClient(t *testing.T) (*GormClient, *recordingDriver) {
+	t.Helper()
+
+	recorder := &recordingDriver{failures: map[string]error{}}
+	registerRecordingDriver.Do(func() { sql.Register("gm_recording", &recordingDriverProxy{}) })
+	recordingDrivers.Store(t.Name(), recorder)
+
+	sqlDB, _ := sql.Open("gm_recording", t.Name())
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("gorm.Open err, msg: %v", err)
+	}
+	return &GormClient{Name: t.Name(), db: db}, recorder
+}
+
+// database/sql drivers are registered once, the DSN selects the recorder of a test
+var recordingDrivers sync.Map
+
+type recordingDriverProxy struct{}
+
+func (recordingDriverProxy) Open(name string) (driver.Conn, error) {
+	recorder, _ := recordingDrivers.Load(name)
+	return recorder.(*recordingDriver).Open(name)
+}
+
+func TestGormWithTx(t *testing.T) {
+	client, recorder := newRecordingGormClient(t)
+	ctx := context.Background()
+
+	err := client.WithTx(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		tx.Exec("UPDATE a")
+
+		// A failing nested call only rolls back its savepoint
+		nestedErr := client.WithTx(ctx, func(ctx context.Context, tx *gorm.DB) error {
+			client.FromContext(ctx).Exec("UPDATE b")
+			return errors.New("out of stock")
+		})
+		if nestedErr == nil {
+			t.Error("expected the nested error")
+		}
+
+		return client.WithTx(ctx, func(ctx context.Context, tx *gorm.DB) error {
+			return tx.Exec("UPDATE c").Error
+		})
+	})
+	if err != nil {
+		t.Fatalf("WithTx err, msg: %v", err)
+	}
+	expected := "BEGIN; UPDATE a; SAVEPOINT gm_sp_1; UPDATE b; ROLLBACK TO SAVEPOINT gm_sp_1; SAVEPOINT gm_sp_1; UPDATE c; COMMIT"
+	if log := recorder.log(); log != expected {
+		t.Errorf("expected %s, but got %s", expected, log)
+	}
+
+	err = client.WithTx(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		tx.Exec("UPDATE a")
+		return errors.New("failed")
+	})
+	if err == nil || recorder.log() != "BEGIN; UPDATE a; ROLLBACK" {
+		t.Errorf("expected a rollback on error, but got %v", err)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the panic to propagate")
+			}
+		}()
+		client.WithTx(ctx, func(ctx context.Context, tx *gorm.DB) error {
+			panic("boom")
+		})
+	}()
+	if log := recorder.log(); log != "BEGIN; ROLLBACK" {
+		t.Errorf("expected a rollback on panic, but got %s", log)
+	}
+
+	if client.FromContext(ctx).Exec("UPDATE outside"); recorder.log() != "UPDATE outside" {
+		t.Error("expected FromContext to use the client outside of a transaction")
+	}
+}
+
+func TestGormWithTxRetry(t *testing.T) {
+	client, recorder := newRecordingGormClient(t)
+	ctx := context.Background()
+
+	recorder.failures["UPDATE a"] = &mysqldriver.MySQLError{Number: mysqlErrDeadlock, Message: "Deadlock found"}
+	runs := 0
+	err := client.WithTx(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		runs++
+		return tx.Exec("UPDATE a").Error
+	})
+	if err != nil || runs != 2 {
+		t.Errorf("expected success on run 2, but got %v after %d runs", err, runs)
+	}
+	if log := recorder.log(); log != "BEGIN; UPDATE a; ROLLBACK; BEGIN; UPDATE a; COMMIT" {
+		t.Errorf("unexpected statements %s", log)
+	}
+
+	// Other errors are not retried
+	recorder.failures["UPDATE a"] = &mysqldriver.MySQLError{Number: 1062, Message: "Duplicate entry"}
+	runs = 0
+	err = client.WithTxOptions(ctx, &GormTxOptions{MaxAttempts: 5}, func(ctx context.Context, tx *gorm.DB) error {
+		runs++
+		return tx.Exec("UPDATE a").Error
+	})
+	if err == nil || runs != 1 {
+		t.Errorf("expected a single run for a duplicate entry, but got %v after %d runs", err, runs)
+	}
+}
@@ -2,15 +2,26 @@ package gm
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/fs"
+	"os"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
 type RedisClient struct {
+	redisCommands
+	client redis.UniversalClient
+}
+
+// Command wrappers shared by RedisClient and RedisTx
+type redisCommands struct {
 	Context context.Context
-	client  *redis.Client
+	cmd     redis.Cmdable // runs the commands, the client itself or the connection of a Watch
+	scripts *scriptRegistry
 }
 
 func InitRedis[T number](ctx context.Context, username, password, address string, db T) (redisClient *RedisClient, err error) {
@@ -23,152 +34,332 @@ func InitRedisFromURL(ctx context.Context, url string) (redisClient *RedisClient
 		return nil, err
 	}
 
-	client := redis.NewClient(opt)
+	return newRedisClient(ctx, redis.NewClient(opt))
+}
+
+/*
+Connection of every deployment mode, the mode is chosen from the fields:
+  - MasterName set: sentinel failover, Addrs are the sentinel addresses
+  - several Addrs or Cluster set: redis cluster, Addrs are seed nodes
+  - otherwise a single node at Addrs[0]
+*/
+type RedisOptions struct {
+	Addrs      []string
+	MasterName string
+	Cluster    bool // use the cluster protocol with a single seed address
+
+	Username         string
+	Password         string
+	SentinelUsername string
+	SentinelPassword string
+	DB               int // ignored by cluster
+
+	TLSConfig *tls.Config // plain TCP when nil, see NewRedisTLSConfig
+	Scripts   fs.FS       // .lua files registered and loaded on connect, see LoadScripts
+
+	PoolSize     int // 10 per CPU when zero
+	MinIdleConns int
+	MaxRetries   int           // 3 when zero, -1 disables retries
+	DialTimeout  time.Duration // 5s when zero
+	ReadTimeout  time.Duration // 3s when zero, -1 disables the timeout
+	WriteTimeout time.Duration // ReadTimeout when zero
+	PoolTimeout  time.Duration // ReadTimeout + 1s when zero
+	IdleTimeout  time.Duration // 5m when zero, -1 disables idle closing
+}
+
+/*
+Connect to a single node, a sentinel failover group or a cluster, the client is pinged before it is returned
+
+	redisClient, err := gm.InitRedisWithOptions(ctx, &gm.RedisOptions{
+		Addrs:     []string{"node-1:6379", "node-2:6379", "node-3:6379"},
+		Password:  password,
+		TLSConfig: tlsConfig,
+		PoolSize:  50,
+	})
+*/
+func InitRedisWithOptions(ctx context.Context, options *RedisOptions) (redisClient *RedisClient, err error) {
+	if options == nil || len(options.Addrs) == 0 {
+		return nil, fmt.Errorf("redis options without addresses")
+	}
+
+	universal := &redis.UniversalOptions{
+		Addrs:            options.Addrs,
+		MasterName:       options.MasterName,
+		Username:         options.Username,
+		Password:         options.Password,
+		SentinelUsername: options.SentinelUsername,
+		SentinelPassword: options.SentinelPassword,
+		DB:               options.DB,
+		TLSConfig:        options.TLSConfig,
+		PoolSize:         options.PoolSize,
+		MinIdleConns:     options.MinIdleConns,
+		MaxRetries:       options.MaxRetries,
+		DialTimeout:      options.DialTimeout,
+		ReadTimeout:      options.ReadTimeout,
+		WriteTimeout:     options.WriteTimeout,
+		PoolTimeout:      options.PoolTimeout,
+		IdleTimeout:      options.IdleTimeout,
+	}
+
+	var client redis.UniversalClient
+	switch {
+	case options.MasterName != "":
+		client = redis.NewFailoverClient(universal.Failover())
+	case options.Cluster || len(options.Addrs) > 1:
+		client = redis.NewClusterClient(universal.Cluster())
+	default:
+		client = redis.NewClient(universal.Simple())
+	}
+
+	redisClient, err = newRedisClient(ctx, client)
+	if err != nil || options.Scripts == nil {
+		return redisClient, err
+	}
+	if err := redisClient.LoadScripts(options.Scripts); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return redisClient, nil
+}
+
+// Connect to a redis cluster through its seed nodes
+func InitRedisCluster(ctx context.Context, username, password string, addrs ...string) (redisClient *RedisClient, err error) {
+	return InitRedisWithOptions(ctx, &RedisOptions{Addrs: addrs, Cluster: true, Username: username, Password: password})
+}
+
+// Connect to the master of a sentinel failover group, password is the password of the redis nodes
+func InitRedisSentinel[T number](ctx context.Context, masterName, password string, db T, sentinelAddrs ...string) (redisClient *RedisClient, err error) {
+	return InitRedisWithOptions(ctx, &RedisOptions{Addrs: sentinelAddrs, MasterName: masterName, Password: password, DB: int(db)})
+}
+
+/*
+TLS config for managed redis, every file is optional:
+caFile replaces the system roots to verify the server, certFile and keyFile are the client certificate
+*/
+func NewRedisTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
 
+	if caFile != "" {
+		ca, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("os.ReadFile %s err %v", caFile, err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in %s", caFile)
+		}
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls.LoadX509KeyPair err %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func newRedisClient(ctx context.Context, client redis.UniversalClient) (*RedisClient, error) {
 	ping := client.Ping(ctx)
 	if ping.Val() != "PONG" || ping.Err() != nil {
+		client.Close()
 		return nil, fmt.Errorf("unable to ping redis server client, got %v", ping.Err())
 	}
 
-	return &RedisClient{Context: ctx, client: client}, nil
+	// Values of ctx are kept for the commands, its cancellation only applies to the ping
+	return &RedisClient{redisCommands: redisCommands{Context: context.WithoutCancel(ctx), cmd: client, scripts: newScriptRegistry()}, client: client}, nil
+}
+
+/*
+A view of the client running its commands with ctx, it shares the connection pool and is cheap to create per request
+
+	func GetProfile(c *gin.Context) {
+		name, err := redisClient.WithContext(c.Request.Context()).HGet("profile:"+id, "name").Result()
+	}
+*/
+func (redisClient *RedisClient) WithContext(ctx context.Context) *RedisClient {
+	return &RedisClient{redisCommands: redisCommands{Context: ctx, cmd: redisClient.cmd, scripts: redisClient.scripts}, client: redisClient.client}
 }
 
 /*Base Commands*/
 // ------------------------------------------------------------------------------
+/*
+Client of a single node or of a sentinel master, nil when connected to a cluster.
+
+Deprecated: use NodeClient to detect a cluster, or UniversalClient which works in every mode.
+*/
 func (redisClient *RedisClient) Client() *redis.Client {
+	client, _ := redisClient.NodeClient()
+	return client
+}
+
+// Client of a single node or of a sentinel master, false when connected to a cluster
+func (redisClient *RedisClient) NodeClient() (*redis.Client, bool) {
+	client, ok := redisClient.client.(*redis.Client)
+	return client, ok
+}
+
+// Underlying client of every deployment mode
+func (redisClient *RedisClient) UniversalClient() redis.UniversalClient {
 	return redisClient.client
 }
 
-func (redisClient *RedisClient) DBSize() *redis.IntCmd {
-	return redisClient.client.DBSize(redisClient.Context)
+func (redisClient *RedisClient) Close() error {
+	return redisClient.client.Close()
 }
 
-func (redisClient *RedisClient) Keys(parttern string) *redis.StringSliceCmd {
-	return redisClient.client.Keys(redisClient.Context, parttern)
+func (redisClient *redisCommands) DBSize() *redis.IntCmd {
+	return redisClient.cmd.DBSize(redisClient.Context)
 }
 
-func (redisClient *RedisClient) Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
-	return redisClient.client.Set(redisClient.Context, key, value, expiration)
+/*
+Keys matching parttern, the server is blocked while every key is walked.
+
+Deprecated: use ScanIterator, it walks the keys in batches without blocking the server.
+*/
+func (redisClient *redisCommands) Keys(parttern string) *redis.StringSliceCmd {
+	return redisClient.cmd.Keys(redisClient.Context, parttern)
 }
 
-func (redisClient *RedisClient) Get(key string) *redis.StringCmd {
-	return redisClient.client.Get(redisClient.Context, key)
+func (redisClient *redisCommands) Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	return redisClient.cmd.Set(redisClient.Context, key, value, expiration)
 }
 
-func (redisClient *RedisClient) Del(keys ...string) *redis.IntCmd {
-	return redisClient.client.Del(redisClient.Context, keys...)
+func (redisClient *redisCommands) Get(key string) *redis.StringCmd {
+	return redisClient.cmd.Get(redisClient.Context, key)
 }
 
-func (redisClient *RedisClient) Exists(key string) *redis.IntCmd {
-	return redisClient.client.Exists(redisClient.Context, key)
+func (redisClient *redisCommands) Del(keys ...string) *redis.IntCmd {
+	return redisClient.cmd.Del(redisClient.Context, keys...)
+}
+
+func (redisClient *redisCommands) Exists(key string) *redis.IntCmd {
+	return redisClient.cmd.Exists(redisClient.Context, key)
 }
 
 // ------------------------------------------------------------------------------
-func (redisClient *RedisClient) MSet(values ...interface{}) *redis.StatusCmd {
-	return redisClient.client.MSet(redisClient.Context, values...)
+func (redisClient *redisCommands) MSet(values ...interface{}) *redis.StatusCmd {
+	return redisClient.cmd.MSet(redisClient.Context, values...)
 }
 
-func (redisClient *RedisClient) MGet(keys ...string) *redis.SliceCmd {
-	return redisClient.client.MGet(redisClient.Context, keys...)
+func (redisClient *redisCommands) MGet(keys ...string) *redis.SliceCmd {
+	return redisClient.cmd.MGet(redisClient.Context, keys...)
 }
 
 // ------------------------------------------------------------------------------
-func (redisClient *RedisClient) HKeys(key string) *redis.StringSliceCmd {
-	return redisClient.client.HKeys(redisClient.Context, key)
+func (redisClient *redisCommands) HKeys(key string) *redis.StringSliceCmd {
+	return redisClient.cmd.HKeys(redisClient.Context, key)
 }
 
-func (redisClient *RedisClient) HExists(key string, field string) *redis.BoolCmd {
-	return redisClient.client.HExists(redisClient.Context, key, field)
+func (redisClient *redisCommands) HExists(key string, field string) *redis.BoolCmd {
+	return redisClient.cmd.HExists(redisClient.Context, key, field)
 }
 
-func (redisClient *RedisClient) HLen(key string) *redis.IntCmd {
-	return redisClient.client.HLen(redisClient.Context, key)
+func (redisClient *redisCommands) HLen(key string) *redis.IntCmd {
+	return redisClient.cmd.HLen(redisClient.Context, key)
 }
 
-func (redisClient *RedisClient) HSet(key string, value ...interface{}) *redis.IntCmd {
-	return redisClient.client.HSet(redisClient.Context, key, value...)
+func (redisClient *redisCommands) HSet(key string, value ...interface{}) *redis.IntCmd {
+	return redisClient.cmd.HSet(redisClient.Context, key, value...)
 }
 
-func (redisClient *RedisClient) HGetAll(key string) *redis.StringStringMapCmd {
-	return redisClient.client.HGetAll(redisClient.Context, key)
+func (redisClient *redisCommands) HGetAll(key string) *redis.StringStringMapCmd {
+	return redisClient.cmd.HGetAll(redisClient.Context, key)
 }
 
-func (redisClient *RedisClient) HGet(key string, field string) *redis.StringCmd {
-	return redisClient.client.HGet(redisClient.Context, key, field)
+func (redisClient *redisCommands) HGet(key string, field string) *redis.StringCmd {
+	return redisClient.cmd.HGet(redisClient.Context, key, field)
 }
 
-func (redisClient *RedisClient) HDel(key string, field ...string) *redis.IntCmd {
-	return redisClient.client.HDel(redisClient.Context, key, field...)
+func (redisClient *redisCommands) HDel(key string, field ...string) *redis.IntCmd {
+	return redisClient.cmd.HDel(redisClient.Context, key, field...)
 }
 
 /* Redis Transaction Pipeline*/
 type RedisTxPipeline struct {
+	redisPipelineCommands
+}
+
+// Command wrappers shared by RedisTxPipeline and RedisPipeline
+type redisPipelineCommands struct {
 	Context context.Context
 	tx      redis.Pipeliner
+	scripts *scriptRegistry
 }
 
 func (redisClient *RedisClient) NewTxPipeline() *RedisTxPipeline {
-	return &RedisTxPipeline{Context: redisClient.Context, tx: redisClient.client.TxPipeline()}
+	return &RedisTxPipeline{redisPipelineCommands{Context: redisClient.Context, tx: redisClient.cmd.TxPipeline(), scripts: redisClient.scripts}}
+}
+
+// Queue the following commands and run Commit with ctx
+func (pipeline *RedisTxPipeline) WithContext(ctx context.Context) *RedisTxPipeline {
+	return &RedisTxPipeline{redisPipelineCommands{Context: ctx, tx: pipeline.tx, scripts: pipeline.scripts}}
 }
 
-func (pipeline *RedisTxPipeline) Pipeliner() redis.Pipeliner {
+func (pipeline *redisPipelineCommands) Pipeliner() redis.Pipeliner {
 	return pipeline.tx
 }
 
-func (pipeline *RedisTxPipeline) Keys(parttern string) *redis.StringSliceCmd {
+/*
+Keys matching parttern, the server is blocked while every key is walked.
+
+Deprecated: use ScanIterator of the client, it walks the keys in batches without blocking the server.
+*/
+func (pipeline *redisPipelineCommands) Keys(parttern string) *redis.StringSliceCmd {
 	return pipeline.tx.Keys(pipeline.Context, parttern)
 }
 
-func (pipeline *RedisTxPipeline) Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+func (pipeline *redisPipelineCommands) Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
 	return pipeline.tx.Set(pipeline.Context, key, value, expiration)
 }
 
-func (pipeline *RedisTxPipeline) Get(key string) *redis.StringCmd {
+func (pipeline *redisPipelineCommands) Get(key string) *redis.StringCmd {
 	return pipeline.tx.Get(pipeline.Context, key)
 }
 
-func (pipeline *RedisTxPipeline) Del(keys ...string) *redis.IntCmd {
+func (pipeline *redisPipelineCommands) Del(keys ...string) *redis.IntCmd {
 	return pipeline.tx.Del(pipeline.Context, keys...)
 }
 
 // ------------------------------------------------------------------------------
-func (pipeline *RedisTxPipeline) MSet(values ...interface{}) *redis.StatusCmd {
+func (pipeline *redisPipelineCommands) MSet(values ...interface{}) *redis.StatusCmd {
 	return pipeline.tx.MSet(pipeline.Context, values...)
 }
 
-func (pipeline *RedisTxPipeline) MGet(keys ...string) *redis.SliceCmd {
+func (pipeline *redisPipelineCommands) MGet(keys ...string) *redis.SliceCmd {
 	return pipeline.tx.MGet(pipeline.Context, keys...)
 }
 
 // ------------------------------------------------------------------------------
-func (pipeline *RedisTxPipeline) HKeys(key string) *redis.StringSliceCmd {
+func (pipeline *redisPipelineCommands) HKeys(key string) *redis.StringSliceCmd {
 	return pipeline.tx.HKeys(pipeline.Context, key)
 }
 
-func (pipeline *RedisTxPipeline) HExists(key string, field string) *redis.BoolCmd {
+func (pipeline *redisPipelineCommands) HExists(key string, field string) *redis.BoolCmd {
 	return pipeline.tx.HExists(pipeline.Context, key, field)
 }
 
-func (pipeline *RedisTxPipeline) HLen(key string) *redis.IntCmd {
+func (pipeline *redisPipelineCommands) HLen(key string) *redis.IntCmd {
 	return pipeline.tx.HLen(pipeline.Context, key)
 }
 
-func (pipeline *RedisTxPipeline) HSet(key string, value ...interface{}) *redis.IntCmd {
+func (pipeline *redisPipelineCommands) HSet(key string, value ...interface{}) *redis.IntCmd {
 	return pipeline.tx.HSet(pipeline.Context, key, value...)
 }
 
-func (pipeline *RedisTxPipeline) HGetAll(key string) *redis.StringStringMapCmd {
+func (pipeline *redisPipelineCommands) HGetAll(key string) *redis.StringStringMapCmd {
 	return pipeline.tx.HGetAll(pipeline.Context, key)
 }
 
-func (pipeline *RedisTxPipeline) HGet(key string, field string) *redis.StringCmd {
+func (pipeline *redisPipelineCommands) HGet(key string, field string) *redis.StringCmd {
 	return pipeline.tx.HGet(pipeline.Context, key, field)
 }
 
-func (pipeline *RedisTxPipeline) HDel(key string, field ...string) *redis.IntCmd {
+func (pipeline *redisPipelineCommands) HDel(key string, field ...string) *redis.IntCmd {
 	return pipeline.tx.HDel(pipeline.Context, key, field...)
 }
 
-func (pipeline *RedisTxPipeline) Commit() ([]redis.Cmder, error) {
+func (pipeline *redisPipelineCommands) Commit() ([]redis.Cmder, error) {
 	return pipeline.tx.Exec(pipeline.Context)
 }
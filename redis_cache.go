@@ -0,0 +1,213 @@
+package gm
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/vmihailenco/msgpack/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	// Get found no entry for the key
+	ErrCacheMiss = errors.New("cache miss")
+	// The key is known not to exist, returned by loaders to enable negative caching and by Get for negative entries
+	ErrCacheNotFound = errors.New("cache entry not found")
+)
+
+// Every stored value starts with a marker byte, negative entries have no payload
+const (
+	cacheMarkerNegative byte = 0
+	cacheMarkerValue    byte = 1
+)
+
+type CacheCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	JSONCodec    CacheCodec = jsonCodec{}
+	MsgpackCodec CacheCodec = msgpackCodec{}
+	GobCodec     CacheCodec = gobCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error)      { return msgpack.Marshal(v) }
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error { return msgpack.Unmarshal(data, v) }
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(v); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type CacheOptions struct {
+	Prefix      string        // prepended to every key, e.g. "user:"
+	Codec       CacheCodec    // JSONCodec when nil
+	TTL         time.Duration // expiration of Set and loaded values, no expiration when zero
+	Jitter      float64       // random spread of the TTL, 0.1 expires values between 90% and 110% of the TTL
+	NegativeTTL time.Duration // expiration of ErrCacheNotFound results of loaders, negative caching is disabled when zero
+}
+
+// Typed cache of T values stored in redis
+type RedisCache[T any] struct {
+	redisClient *RedisClient
+	options     CacheOptions
+	group       singleflight.Group
+}
+
+/*
+Create a cache of T values
+
+	type User struct {
+		ID   int64
+		Name string
+	}
+
+	users := gm.NewRedisCache[User](redisClient, gm.CacheOptions{Prefix: "user:", TTL: time.Hour, Jitter: 0.1, NegativeTTL: time.Minute})
+	user, err := users.GetOrLoad("42", func() (User, error) {
+		var user User
+		if err := gm.NewGormSession().First(&user, 42).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, gm.ErrCacheNotFound
+		}
+		return user, err
+	})
+*/
+func NewRedisCache[T any](redisClient *RedisClient, options CacheOptions) *RedisCache[T] {
+	if options.Codec == nil {
+		options.Codec = JSONCodec
+	}
+	return &RedisCache[T]{redisClient: redisClient, options: options}
+}
+
+// Cached value of key, ErrCacheMiss when absent and ErrCacheNotFound for negative entries
+func (cache *RedisCache[T]) Get(key string) (value T, err error) {
+	data, err := cache.redisClient.Get(cache.options.Prefix + key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return value, ErrCacheMiss
+	}
+	if err != nil {
+		return value, fmt.Errorf("redis.Get %s err %v", key, err)
+	}
+
+	if len(data) == 0 {
+		return value, fmt.Errorf("cache entry %s without marker", key)
+	}
+	if data[0] == cacheMarkerNegative {
+		return value, ErrCacheNotFound
+	}
+
+	if err := cache.options.Codec.Unmarshal(data[1:], &value); err != nil {
+		return value, fmt.Errorf("cache decode %s err %v", key, err)
+	}
+	return value, nil
+}
+
+// Store value with the TTL of the options
+func (cache *RedisCache[T]) Set(key string, value T) error {
+	return cache.SetWithTTL(key, value, cache.options.TTL)
+}
+
+// Store value with ttl plus the jitter of the options, zero keeps the value without expiration
+func (cache *RedisCache[T]) SetWithTTL(key string, value T, ttl time.Duration) error {
+	data, err := cache.options.Codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("cache encode %s err %v", key, err)
+	}
+
+	return cache.set(key, append([]byte{cacheMarkerValue}, data...), ttl)
+}
+
+// Remember that key does not exist for the NegativeTTL of the options
+func (cache *RedisCache[T]) SetNotFound(key string) error {
+	if cache.options.NegativeTTL <= 0 {
+		return nil
+	}
+	return cache.set(key, []byte{cacheMarkerNegative}, cache.options.NegativeTTL)
+}
+
+func (cache *RedisCache[T]) Delete(keys ...string) error {
+	prefixed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		prefixed = append(prefixed, cache.options.Prefix+key)
+	}
+
+	if err := cache.redisClient.Del(prefixed...).Err(); err != nil {
+		return fmt.Errorf("redis.Del err %v", err)
+	}
+	return nil
+}
+
+/*
+Cached value of key, or the result of loader stored in the cache.
+Concurrent misses of the same key share a single loader call.
+A loader returning ErrCacheNotFound is cached as a negative entry when NegativeTTL is set,
+other loader errors are returned without being cached.
+When redis is unavailable the loader result is returned without caching.
+*/
+func (cache *RedisCache[T]) GetOrLoad(key string, loader func() (T, error)) (T, error) {
+	value, err := cache.Get(key)
+	if err == nil || errors.Is(err, ErrCacheNotFound) {
+		return value, err
+	}
+
+	result, err, _ := cache.group.Do(key, func() (interface{}, error) {
+		// Another caller may have loaded the key between the miss and joining the group
+		if value, err := cache.Get(key); err == nil || errors.Is(err, ErrCacheNotFound) {
+			return value, err
+		}
+
+		value, err := loader()
+		if errors.Is(err, ErrCacheNotFound) {
+			cache.SetNotFound(key)
+			return value, ErrCacheNotFound
+		}
+		if err != nil {
+			return value, err
+		}
+
+		cache.Set(key, value)
+		return value, nil
+	})
+
+	value, _ = result.(T)
+	return value, err
+}
+
+func (cache *RedisCache[T]) set(key string, data []byte, ttl time.Duration) error {
+	if err := cache.redisClient.Set(cache.options.Prefix+key, data, cache.jitter(ttl)).Err(); err != nil {
+		return fmt.Errorf("redis.Set %s err %v", key, err)
+	}
+	return nil
+}
+
+func (cache *RedisCache[T]) jitter(ttl time.Duration) time.Duration {
+	if ttl <= 0 || cache.options.Jitter <= 0 {
+		return ttl
+	}
+
+	spread := float64(ttl) * cache.options.Jitter
+	jittered := time.Duration(float64(ttl) + (rand.Float64()*2-1)*spread)
+	return max(jittered, time.Millisecond)
+}
@@ -0,0 +1,163 @@
+package gm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+type testCacheUser struct {
+	ID   int64
+	Name string
+	Tags []string
+}
+
+func newTestRedisClient(t *testing.T) (*RedisClient, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	redisClient, err := InitRedisFromURL(context.Background(), "redis://"+server.Addr())
+	if err != nil {
+		t.Fatalf("InitRedisFromURL err, msg: %v", err)
+	}
+	t.Cleanup(func() { redisClient.Close() })
+	return redisClient, server
+}
+
+func TestRedisCacheCodecs(t *testing.T) {
+	redisClient, _ := newTestRedisClient(t)
+	user := testCacheUser{ID: 42, Name: "alice", Tags: []string{"admin"}}
+
+	for name, codec := range map[string]CacheCodec{"json": JSONCodec, "msgpack": MsgpackCodec, "gob": GobCodec} {
+		t.Run(name, func(t *testing.T) {
+			cache := NewRedisCache[testCacheUser](redisClient, CacheOptions{Prefix: name + ":", Codec: codec})
+
+			if _, err := cache.Get("42"); !errors.Is(err, ErrCacheMiss) {
+				t.Fatalf("expected ErrCacheMiss, but got %v", err)
+			}
+			if err := cache.Set("42", user); err != nil {
+				t.Fatalf("Set err, msg: %v", err)
+			}
+
+			result, err := cache.Get("42")
+			if err != nil {
+				t.Fatalf("Get err, msg: %v", err)
+			}
+			if result.ID != user.ID || result.Name != user.Name || len(result.Tags) != 1 {
+				t.Errorf("expected %+v, but got %+v", user, result)
+			}
+
+			if err := cache.Delete("42"); err != nil {
+				t.Fatalf("Delete err, msg: %v", err)
+			}
+			if _, err := cache.Get("42"); !errors.Is(err, ErrCacheMiss) {
+				t.Errorf("expected ErrCacheMiss after Delete, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestRedisCacheTTLJitter(t *testing.T) {
+	redisClient, server := newTestRedisClient(t)
+	cache := NewRedisCache[int](redisClient, CacheOptions{TTL: 100 * time.Second, Jitter: 0.2})
+
+	spread := map[time.Duration]bool{}
+	for i := 0; i < 20; i++ {
+		key := string(rune('a' + i))
+		cache.Set(key, i)
+
+		ttl := server.TTL(key)
+		if ttl < 80*time.Second || ttl > 120*time.Second {
+			t.Errorf("ttl %s outside of the jitter range", ttl)
+		}
+		spread[ttl] = true
+	}
+	if len(spread) < 2 {
+		t.Error("expected jittered ttls to differ")
+	}
+
+	cache.SetWithTTL("forever", 1, 0)
+	if ttl := server.TTL("forever"); ttl != 0 {
+		t.Errorf("expected no expiration, but got %s", ttl)
+	}
+}
+
+func TestRedisCacheNegative(t *testing.T) {
+	redisClient, server := newTestRedisClient(t)
+	cache := NewRedisCache[testCacheUser](redisClient, CacheOptions{Prefix: "user:", TTL: time.Hour, NegativeTTL: time.Minute})
+
+	calls := 0
+	loader := func() (testCacheUser, error) {
+		calls++
+		return testCacheUser{}, ErrCacheNotFound
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.GetOrLoad("404", loader); !errors.Is(err, ErrCacheNotFound) {
+			t.Fatalf("expected ErrCacheNotFound, but got %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the negative result to be cached, but the loader ran %d times", calls)
+	}
+	if ttl := server.TTL("user:404"); ttl != time.Minute {
+		t.Errorf("expected the negative ttl, but got %s", ttl)
+	}
+
+	// Loader failures other than ErrCacheNotFound are not cached
+	failing := errors.New("db down")
+	for i := 0; i < 2; i++ {
+		if _, err := cache.GetOrLoad("500", func() (testCacheUser, error) { calls++; return testCacheUser{}, failing }); !errors.Is(err, failing) {
+			t.Fatalf("expected the loader error, but got %v", err)
+		}
+	}
+	if calls != 3 || server.Exists("user:500") {
+		t.Errorf("expected loader errors not to be cached")
+	}
+}
+
+func TestRedisCacheSingleflight(t *testing.T) {
+	redisClient, _ := newTestRedisClient(t)
+	cache := NewRedisCache[testCacheUser](redisClient, CacheOptions{TTL: time.Hour})
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func() (testCacheUser, error) {
+		calls.Add(1)
+		<-release
+		return testCacheUser{ID: 7, Name: "bob"}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan testCacheUser, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user, err := cache.GetOrLoad("7", loader)
+			if err != nil {
+				t.Errorf("GetOrLoad err, msg: %v", err)
+			}
+			results <- user
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if calls.Load() != 1 {
+		t.Errorf("expected a single loader call, but got %d", calls.Load())
+	}
+	for user := range results {
+		if user.ID != 7 || user.Name != "bob" {
+			t.Errorf("unexpected result %+v", user)
+		}
+	}
+}
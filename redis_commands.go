@@ -0,0 +1,653 @@
+package gm
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Keys
+// ------------------------------------------------------------------------------
+func (redisClient *redisCommands) Expire(key string, expiration time.Duration) *redis.BoolCmd {
+	return redisClient.cmd.Expire(redisClient.Context, key, expiration)
+}
+
+func (redisClient *redisCommands) ExpireAt(key string, tm time.Time) *redis.BoolCmd {
+	return redisClient.cmd.ExpireAt(redisClient.Context, key, tm)
+}
+
+func (redisClient *redisCommands) PExpire(key string, expiration time.Duration) *redis.BoolCmd {
+	return redisClient.cmd.PExpire(redisClient.Context, key, expiration)
+}
+
+func (redisClient *redisCommands) Persist(key string) *redis.BoolCmd {
+	return redisClient.cmd.Persist(redisClient.Context, key)
+}
+
+func (redisClient *redisCommands) TTL(key string) *redis.DurationCmd {
+	return redisClient.cmd.TTL(redisClient.Context, key)
+}
+
+func (redisClient *redisCommands) PTTL(key string) *redis.DurationCmd {
+	return redisClient.cmd.PTTL(redisClient.Context, key)
+}
+
+func (redisClient *redisCommands) Type(key string) *redis.StatusCmd {
+	return redisClient.cmd.Type(redisClient.Context, key)
+}
+
+func (redisClient *redisCommands) Rename(key, newkey string) *redis.StatusCmd {
+	return redisClient.cmd.Rename(redisClient.Context, key, newkey)
+}
+
+func (redisClient *redisCommands) Unlink(keys ...string) *redis.IntCmd {
+	return redisClient.cmd.Unlink(redisClient.Context, keys...)
+}
+
+func (redisClient *redisCommands) Scan(cursor uint64, match string, count int64) *redis.ScanCmd {
+	return redisClient.cmd.Scan(redisClient.Context, cursor, match, count)
+}
+
+// Strings
+// ------------------------------------------------------------------------------
+func (redisClient *redisCommands) SetNX(key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	return redisClient.cmd.SetNX(redisClient.Context, key, value, expiration)
+}
+
+func (redisClient *redisCommands) GetSet(key string, value interface{}) *redis.StringCmd {
+	return redisClient.cmd.GetSet(redisClient.Context, key, value)
+}
+
+func (redisClient *redisCommands) GetDel(key string) *redis.StringCmd {
+	return redisClient.cmd.GetDel(redisClient.Context, key)
+}
+
+func (redisClient *redisCommands) Incr(key string) *redis.IntCmd {
+	return redisClient.cmd.Incr(redisClient.Context, key)
+}
+
+func (redisClient *redisCommands) IncrBy(key string, value int64) *redis.IntCmd {
+	return redisClient.cmd.IncrBy(redisClient.Context, key, value)
+}
+
+func (redisClient *redisCommands) IncrByFloat(key string, value float64) *redis.FloatCmd {
+	return redisClient.cmd.IncrByFloat(redisClient.Context, key, value)
+}
+
+func (redisClient *redisCommands) Decr(key string) *redis.IntCmd {
+	return redisClient.cmd.Decr(redisClient.Context, key)
+}
+
+func (redisClient *redisCommands) DecrBy(key string, value int64) *redis.IntCmd {
+	return redisClient.cmd.DecrBy(redisClient.Context, key, value)
+}
+
+// Hashes
+// ------------------------------------------------------------------------------
+func (redisClient *redisCommands) HMGet(key string, fields ...string) *redis.SliceCmd {
+	return redisClient.cmd.HMGet(redisClient.Context, key, fields...)
+}
+
+func (redisClient *redisCommands) HSetNX(key, field string, value interface{}) *redis.BoolCmd {
+	return redisClient.cmd.HSetNX(redisClient.Context, key, field, value)
+}
+
+func (redisClient *redisCommands) HIncrBy(key, field string, incr int64) *redis.IntCmd {
+	return redisClient.cmd.HIncrBy(redisClient.Context, key, field, incr)
+}
+
+func (redisClient *redisCommands) HIncrByFloat(key, field string, incr float64) *redis.FloatCmd {
+	return redisClient.cmd.HIncrByFloat(redisClient.Context, key, field, incr)
+}
+
+func (redisClient *redisCommands) HVals(key string) *redis.StringSliceCmd {
+	return redisClient.cmd.HVals(redisClient.Context, key)
+}
+
+func (redisClient *redisCommands) HScan(key string, cursor uint64, match string, count int64) *redis.ScanCmd {
+	return redisClient.cmd.HScan(redisClient.Context, key, cursor, match, count)
+}
+
+// Lists
+// ------------------------------------------------------------------------------
+func (redisClient *redisCommands) LPush(key string, values ...interface{}) *redis.IntCmd {
+	return redisClient.cmd.LPush(redisClient.Context, key, values...)
+}
+
+func (redisClient *redisCommands) RPush(key string, values ...interface{}) *redis.IntCmd {
+	return redisClient.cmd.RPush(redisClient.Context, key, values...)
+}
+
+func (redisClient *redisCommands) LPop(key string) *redis.StringCmd {
+	return redisClient.cmd.LPop(redisClient.Context, key)
+}
+
+func (redisClient *redisCommands) RPop(key string) *redis.StringCmd {
+	return redisClient.cmd.RPop(redisClient.Context, key)
+}
+
+func (redisClient *redisCommands) BLPop(timeout time.Duration, keys ...string) *redis.StringSliceCmd {
+	return redisClient.cmd.BLPop(redisClient.Context, timeout, keys...)
+}
+
+func (redisClient *redisCommands) BRPop(timeout time.Duration, keys ...string) *redis.StringSliceCmd {
+	return redisClient.cmd.BRPop(redisClient.Context, timeout, keys...)
+}
+
+func (redisClient *redisCommands) LMove(source, destination, srcpos, destpos string) *redis.StringCmd {
+	return redisClient.cmd.LMove(redisClient.Context, source, destination, srcpos, destpos)
+}
+
+func (redisClient *redisCommands) LLen(key string) *redis.IntCmd {
+	return redisClient.cmd.LLen(redisClient.Context, key)
+}
+
+func (redisClient *redisCommands) LIndex(key string, index int64) *redis.StringCmd {
+	return redisClient.cmd.LIndex(redisClient.Context, key, index)
+}
+
+func (redisClient *redisCommands) LRange(key string, start, stop int64) *redis.StringSliceCmd {
+	return redisClient.cmd.LRange(redisClient.Context, key, start, stop)
+}
+
+func (redisClient *redisCommands) LSet(key string, index int64, value interface{}) *redis.StatusCmd {
+	return redisClient.cmd.LSet(redisClient.Context, key, index, value)
+}
+
+func (redisClient *redisCommands) LRem(key string, count int64, value interface{}) *redis.IntCmd {
+	return redisClient.cmd.LRem(redisClient.Context, key, count, value)
+}
+
+func (redisClient *redisCommands) LTrim(key string, start, stop int64) *redis.StatusCmd {
+	return redisClient.cmd.LTrim(redisClient.Context, key, start, stop)
+}
+
+// Sets
+// ------------------------------------------------------------------------------
+func (redisClient *redisCommands) SAdd(key string, members ...interface{}) *redis.IntCmd {
+	return redisClient.cmd.SAdd(redisClient.Context, key, members...)
+}
+
+func (redisClient *redisCommands) SRem(key string, members ...interface{}) *redis.IntCmd {
+	return redisClient.cmd.SRem(redisClient.Context, key, members...)
+}
+
+func (redisClient *redisCommands) SMembers(key string) *redis.StringSliceCmd {
+	return redisClient.cmd.SMembers(redisClient.Context, key)
+}
+
+func (redisClient *redisCommands) SIsMember(key string, member interface{}) *redis.BoolCmd {
+	return redisClient.cmd.SIsMember(redisClient.Context, key, member)
+}
+
+func (redisClient *redisCommands) SCard(key string) *redis.IntCmd {
+	return redisClient.cmd.SCard(redisClient.Context, key)
+}
+
+func (redisClient *redisCommands) SPop(key string) *redis.StringCmd {
+	return redisClient.cmd.SPop(redisClient.Context, key)
+}
+
+func (redisClient *redisCommands) SRandMember(key string) *redis.StringCmd {
+	return redisClient.cmd.SRandMember(redisClient.Context, key)
+}
+
+func (redisClient *redisCommands) SInter(keys ...string) *redis.StringSliceCmd {
+	return redisClient.cmd.SInter(redisClient.Context, keys...)
+}
+
+func (redisClient *redisCommands) SUnion(keys ...string) *redis.StringSliceCmd {
+	return redisClient.cmd.SUnion(redisClient.Context, keys...)
+}
+
+func (redisClient *redisCommands) SDiff(keys ...string) *redis.StringSliceCmd {
+	return redisClient.cmd.SDiff(redisClient.Context, keys...)
+}
+
+func (redisClient *redisCommands) SScan(key string, cursor uint64, match string, count int64) *redis.ScanCmd {
+	return redisClient.cmd.SScan(redisClient.Context, key, cursor, match, count)
+}
+
+// Sorted Sets
+// ------------------------------------------------------------------------------
+func (redisClient *redisCommands) ZAdd(key string, members ...*redis.Z) *redis.IntCmd {
+	return redisClient.cmd.ZAdd(redisClient.Context, key, members...)
+}
+
+func (redisClient *redisCommands) ZIncrBy(key string, increment float64, member string) *redis.FloatCmd {
+	return redisClient.cmd.ZIncrBy(redisClient.Context, key, increment, member)
+}
+
+func (redisClient *redisCommands) ZRem(key string, members ...interface{}) *redis.IntCmd {
+	return redisClient.cmd.ZRem(redisClient.Context, key, members...)
+}
+
+func (redisClient *redisCommands) ZScore(key, member string) *redis.FloatCmd {
+	return redisClient.cmd.ZScore(redisClient.Context, key, member)
+}
+
+func (redisClient *redisCommands) ZRank(key, member string) *redis.IntCmd {
+	return redisClient.cmd.ZRank(redisClient.Context, key, member)
+}
+
+func (redisClient *redisCommands) ZRevRank(key, member string) *redis.IntCmd {
+	return redisClient.cmd.ZRevRank(redisClient.Context, key, member)
+}
+
+func (redisClient *redisCommands) ZCard(key string) *redis.IntCmd {
+	return redisClient.cmd.ZCard(redisClient.Context, key)
+}
+
+func (redisClient *redisCommands) ZCount(key, min, max string) *redis.IntCmd {
+	return redisClient.cmd.ZCount(redisClient.Context, key, min, max)
+}
+
+func (redisClient *redisCommands) ZRange(key string, start, stop int64) *redis.StringSliceCmd {
+	return redisClient.cmd.ZRange(redisClient.Context, key, start, stop)
+}
+
+func (redisClient *redisCommands) ZRangeWithScores(key string, start, stop int64) *redis.ZSliceCmd {
+	return redisClient.cmd.ZRangeWithScores(redisClient.Context, key, start, stop)
+}
+
+func (redisClient *redisCommands) ZRevRange(key string, start, stop int64) *redis.StringSliceCmd {
+	return redisClient.cmd.ZRevRange(redisClient.Context, key, start, stop)
+}
+
+func (redisClient *redisCommands) ZRevRangeWithScores(key string, start, stop int64) *redis.ZSliceCmd {
+	return redisClient.cmd.ZRevRangeWithScores(redisClient.Context, key, start, stop)
+}
+
+func (redisClient *redisCommands) ZRangeByScore(key string, opt *redis.ZRangeBy) *redis.StringSliceCmd {
+	return redisClient.cmd.ZRangeByScore(redisClient.Context, key, opt)
+}
+
+func (redisClient *redisCommands) ZRevRangeByScore(key string, opt *redis.ZRangeBy) *redis.StringSliceCmd {
+	return redisClient.cmd.ZRevRangeByScore(redisClient.Context, key, opt)
+}
+
+func (redisClient *redisCommands) ZRemRangeByScore(key, min, max string) *redis.IntCmd {
+	return redisClient.cmd.ZRemRangeByScore(redisClient.Context, key, min, max)
+}
+
+func (redisClient *redisCommands) ZRemRangeByRank(key string, start, stop int64) *redis.IntCmd {
+	return redisClient.cmd.ZRemRangeByRank(redisClient.Context, key, start, stop)
+}
+
+func (redisClient *redisCommands) ZScan(key string, cursor uint64, match string, count int64) *redis.ScanCmd {
+	return redisClient.cmd.ZScan(redisClient.Context, key, cursor, match, count)
+}
+
+// HyperLogLog
+// ------------------------------------------------------------------------------
+func (redisClient *redisCommands) PFAdd(key string, els ...interface{}) *redis.IntCmd {
+	return redisClient.cmd.PFAdd(redisClient.Context, key, els...)
+}
+
+func (redisClient *redisCommands) PFCount(keys ...string) *redis.IntCmd {
+	return redisClient.cmd.PFCount(redisClient.Context, keys...)
+}
+
+func (redisClient *redisCommands) PFMerge(dest string, keys ...string) *redis.StatusCmd {
+	return redisClient.cmd.PFMerge(redisClient.Context, dest, keys...)
+}
+
+// Scan iterators
+// ------------------------------------------------------------------------------
+/*
+Iterate over SCAN results without blocking the server like Keys does, on a cluster every master is scanned
+
+	iter := redisClient.ScanIterator("session:*", 100)
+	for iter.Next() {
+		fmt.Println(iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+*/
+type RedisScanIterator struct {
+	ctx       context.Context
+	iterators []*redis.ScanIterator
+	err       error
+}
+
+func (iter *RedisScanIterator) Next() bool {
+	for iter.err == nil && len(iter.iterators) > 0 {
+		if iter.iterators[0].Next(iter.ctx) {
+			return true
+		}
+		if iter.err = iter.iterators[0].Err(); iter.err != nil {
+			return false
+		}
+		iter.iterators = iter.iterators[1:]
+	}
+	return false
+}
+
+func (iter *RedisScanIterator) Val() string {
+	if len(iter.iterators) == 0 {
+		return ""
+	}
+	return iter.iterators[0].Val()
+}
+
+func (iter *RedisScanIterator) Err() error {
+	return iter.err
+}
+
+// Keys matching the glob pattern match, count is a hint of the keys returned per call
+func (redisClient *redisCommands) ScanIterator(match string, count int64) *RedisScanIterator {
+	iter := &RedisScanIterator{ctx: redisClient.Context}
+
+	cluster, ok := redisClient.cmd.(*redis.ClusterClient)
+	if !ok {
+		iter.iterators = append(iter.iterators, redisClient.cmd.Scan(redisClient.Context, 0, match, count).Iterator())
+		return iter
+	}
+
+	var mu sync.Mutex
+	iter.err = cluster.ForEachMaster(redisClient.Context, func(ctx context.Context, client *redis.Client) error {
+		mu.Lock()
+		defer mu.Unlock()
+		iter.iterators = append(iter.iterators, client.Scan(redisClient.Context, 0, match, count).Iterator())
+		return nil
+	})
+	return iter
+}
+
+// Fields and values of the hash in turn: field, value, field, value...
+func (redisClient *redisCommands) HScanIterator(key, match string, count int64) *RedisScanIterator {
+	return &RedisScanIterator{ctx: redisClient.Context, iterators: []*redis.ScanIterator{redisClient.cmd.HScan(redisClient.Context, key, 0, match, count).Iterator()}}
+}
+
+func (redisClient *redisCommands) SScanIterator(key, match string, count int64) *RedisScanIterator {
+	return &RedisScanIterator{ctx: redisClient.Context, iterators: []*redis.ScanIterator{redisClient.cmd.SScan(redisClient.Context, key, 0, match, count).Iterator()}}
+}
+
+// Members and scores of the sorted set in turn: member, score, member, score...
+func (redisClient *redisCommands) ZScanIterator(key, match string, count int64) *RedisScanIterator {
+	return &RedisScanIterator{ctx: redisClient.Context, iterators: []*redis.ScanIterator{redisClient.cmd.ZScan(redisClient.Context, key, 0, match, count).Iterator()}}
+}
+
+/* Redis Transaction Pipeline*/
+// Blocking pops are left out, they never block inside MULTI
+
+// Keys
+// ------------------------------------------------------------------------------
+func (pipeline *redisPipelineCommands) Expire(key string, expiration time.Duration) *redis.BoolCmd {
+	return pipeline.tx.Expire(pipeline.Context, key, expiration)
+}
+
+func (pipeline *redisPipelineCommands) ExpireAt(key string, tm time.Time) *redis.BoolCmd {
+	return pipeline.tx.ExpireAt(pipeline.Context, key, tm)
+}
+
+func (pipeline *redisPipelineCommands) PExpire(key string, expiration time.Duration) *redis.BoolCmd {
+	return pipeline.tx.PExpire(pipeline.Context, key, expiration)
+}
+
+func (pipeline *redisPipelineCommands) Persist(key string) *redis.BoolCmd {
+	return pipeline.tx.Persist(pipeline.Context, key)
+}
+
+func (pipeline *redisPipelineCommands) TTL(key string) *redis.DurationCmd {
+	return pipeline.tx.TTL(pipeline.Context, key)
+}
+
+func (pipeline *redisPipelineCommands) PTTL(key string) *redis.DurationCmd {
+	return pipeline.tx.PTTL(pipeline.Context, key)
+}
+
+func (pipeline *redisPipelineCommands) Type(key string) *redis.StatusCmd {
+	return pipeline.tx.Type(pipeline.Context, key)
+}
+
+func (pipeline *redisPipelineCommands) Rename(key, newkey string) *redis.StatusCmd {
+	return pipeline.tx.Rename(pipeline.Context, key, newkey)
+}
+
+func (pipeline *redisPipelineCommands) Unlink(keys ...string) *redis.IntCmd {
+	return pipeline.tx.Unlink(pipeline.Context, keys...)
+}
+
+func (pipeline *redisPipelineCommands) Scan(cursor uint64, match string, count int64) *redis.ScanCmd {
+	return pipeline.tx.Scan(pipeline.Context, cursor, match, count)
+}
+
+// Strings
+// ------------------------------------------------------------------------------
+func (pipeline *redisPipelineCommands) SetNX(key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	return pipeline.tx.SetNX(pipeline.Context, key, value, expiration)
+}
+
+func (pipeline *redisPipelineCommands) GetSet(key string, value interface{}) *redis.StringCmd {
+	return pipeline.tx.GetSet(pipeline.Context, key, value)
+}
+
+func (pipeline *redisPipelineCommands) GetDel(key string) *redis.StringCmd {
+	return pipeline.tx.GetDel(pipeline.Context, key)
+}
+
+func (pipeline *redisPipelineCommands) Incr(key string) *redis.IntCmd {
+	return pipeline.tx.Incr(pipeline.Context, key)
+}
+
+func (pipeline *redisPipelineCommands) IncrBy(key string, value int64) *redis.IntCmd {
+	return pipeline.tx.IncrBy(pipeline.Context, key, value)
+}
+
+func (pipeline *redisPipelineCommands) IncrByFloat(key string, value float64) *redis.FloatCmd {
+	return pipeline.tx.IncrByFloat(pipeline.Context, key, value)
+}
+
+func (pipeline *redisPipelineCommands) Decr(key string) *redis.IntCmd {
+	return pipeline.tx.Decr(pipeline.Context, key)
+}
+
+func (pipeline *redisPipelineCommands) DecrBy(key string, value int64) *redis.IntCmd {
+	return pipeline.tx.DecrBy(pipeline.Context, key, value)
+}
+
+// Hashes
+// ------------------------------------------------------------------------------
+func (pipeline *redisPipelineCommands) HMGet(key string, fields ...string) *redis.SliceCmd {
+	return pipeline.tx.HMGet(pipeline.Context, key, fields...)
+}
+
+func (pipeline *redisPipelineCommands) HSetNX(key, field string, value interface{}) *redis.BoolCmd {
+	return pipeline.tx.HSetNX(pipeline.Context, key, field, value)
+}
+
+func (pipeline *redisPipelineCommands) HIncrBy(key, field string, incr int64) *redis.IntCmd {
+	return pipeline.tx.HIncrBy(pipeline.Context, key, field, incr)
+}
+
+func (pipeline *redisPipelineCommands) HIncrByFloat(key, field string, incr float64) *redis.FloatCmd {
+	return pipeline.tx.HIncrByFloat(pipeline.Context, key, field, incr)
+}
+
+func (pipeline *redisPipelineCommands) HVals(key string) *redis.StringSliceCmd {
+	return pipeline.tx.HVals(pipeline.Context, key)
+}
+
+func (pipeline *redisPipelineCommands) HScan(key string, cursor uint64, match string, count int64) *redis.ScanCmd {
+	return pipeline.tx.HScan(pipeline.Context, key, cursor, match, count)
+}
+
+// Lists
+// ------------------------------------------------------------------------------
+func (pipeline *redisPipelineCommands) LPush(key string, values ...interface{}) *redis.IntCmd {
+	return pipeline.tx.LPush(pipeline.Context, key, values...)
+}
+
+func (pipeline *redisPipelineCommands) RPush(key string, values ...interface{}) *redis.IntCmd {
+	return pipeline.tx.RPush(pipeline.Context, key, values...)
+}
+
+func (pipeline *redisPipelineCommands) LPop(key string) *redis.StringCmd {
+	return pipeline.tx.LPop(pipeline.Context, key)
+}
+
+func (pipeline *redisPipelineCommands) RPop(key string) *redis.StringCmd {
+	return pipeline.tx.RPop(pipeline.Context, key)
+}
+
+func (pipeline *redisPipelineCommands) LMove(source, destination, srcpos, destpos string) *redis.StringCmd {
+	return pipeline.tx.LMove(pipeline.Context, source, destination, srcpos, destpos)
+}
+
+func (pipeline *redisPipelineCommands) LLen(key string) *redis.IntCmd {
+	return pipeline.tx.LLen(pipeline.Context, key)
+}
+
+func (pipeline *redisPipelineCommands) LIndex(key string, index int64) *redis.StringCmd {
+	return pipeline.tx.LIndex(pipeline.Context, key, index)
+}
+
+func (pipeline *redisPipelineCommands) LRange(key string, start, stop int64) *redis.StringSliceCmd {
+	return pipeline.tx.LRange(pipeline.Context, key, start, stop)
+}
+
+func (pipeline *redisPipelineCommands) LSet(key string, index int64, value interface{}) *redis.StatusCmd {
+	return pipeline.tx.LSet(pipeline.Context, key, index, value)
+}
+
+func (pipeline *redisPipelineCommands) LRem(key string, count int64, value interface{}) *redis.IntCmd {
+	return pipeline.tx.LRem(pipeline.Context, key, count, value)
+}
+
+func (pipeline *redisPipelineCommands) LTrim(key string, start, stop int64) *redis.StatusCmd {
+	return pipeline.tx.LTrim(pipeline.Context, key, start, stop)
+}
+
+// Sets
+// ------------------------------------------------------------------------------
+func (pipeline *redisPipelineCommands) SAdd(key string, members ...interface{}) *redis.IntCmd {
+	return pipeline.tx.SAdd(pipeline.Context, key, members...)
+}
+
+func (pipeline *redisPipelineCommands) SRem(key string, members ...interface{}) *redis.IntCmd {
+	return pipeline.tx.SRem(pipeline.Context, key, members...)
+}
+
+func (pipeline *redisPipelineCommands) SMembers(key string) *redis.StringSliceCmd {
+	return pipeline.tx.SMembers(pipeline.Context, key)
+}
+
+func (pipeline *redisPipelineCommands) SIsMember(key string, member interface{}) *redis.BoolCmd {
+	return pipeline.tx.SIsMember(pipeline.Context, key, member)
+}
+
+func (pipeline *redisPipelineCommands) SCard(key string) *redis.IntCmd {
+	return pipeline.tx.SCard(pipeline.Context, key)
+}
+
+func (pipeline *redisPipelineCommands) SPop(key string) *redis.StringCmd {
+	return pipeline.tx.SPop(pipeline.Context, key)
+}
+
+func (pipeline *redisPipelineCommands) SRandMember(key string) *redis.StringCmd {
+	return pipeline.tx.SRandMember(pipeline.Context, key)
+}
+
+func (pipeline *redisPipelineCommands) SInter(keys ...string) *redis.StringSliceCmd {
+	return pipeline.tx.SInter(pipeline.Context, keys...)
+}
+
+func (pipeline *redisPipelineCommands) SUnion(keys ...string) *redis.StringSliceCmd {
+	return pipeline.tx.SUnion(pipeline.Context, keys...)
+}
+
+func (pipeline *redisPipelineCommands) SDiff(keys ...string) *redis.StringSliceCmd {
+	return pipeline.tx.SDiff(pipeline.Context, keys...)
+}
+
+func (pipeline *redisPipelineCommands) SScan(key string, cursor uint64, match string, count int64) *redis.ScanCmd {
+	return pipeline.tx.SScan(pipeline.Context, key, cursor, match, count)
+}
+
+// Sorted Sets
+// ------------------------------------------------------------------------------
+func (pipeline *redisPipelineCommands) ZAdd(key string, members ...*redis.Z) *redis.IntCmd {
+	return pipeline.tx.ZAdd(pipeline.Context, key, members...)
+}
+
+func (pipeline *redisPipelineCommands) ZIncrBy(key string, increment float64, member string) *redis.FloatCmd {
+	return pipeline.tx.ZIncrBy(pipeline.Context, key, increment, member)
+}
+
+func (pipeline *redisPipelineCommands) ZRem(key string, members ...interface{}) *redis.IntCmd {
+	return pipeline.tx.ZRem(pipeline.Context, key, members...)
+}
+
+func (pipeline *redisPipelineCommands) ZScore(key, member string) *redis.FloatCmd {
+	return pipeline.tx.ZScore(pipeline.Context, key, member)
+}
+
+func (pipeline *redisPipelineCommands) ZRank(key, member string) *redis.IntCmd {
+	return pipeline.tx.ZRank(pipeline.Context, key, member)
+}
+
+func (pipeline *redisPipelineCommands) ZRevRank(key, member string) *redis.IntCmd {
+	return pipeline.tx.ZRevRank(pipeline.Context, key, member)
+}
+
+func (pipeline *redisPipelineCommands) ZCard(key string) *redis.IntCmd {
+	return pipeline.tx.ZCard(pipeline.Context, key)
+}
+
+func (pipeline *redisPipelineCommands) ZCount(key, min, max string) *redis.IntCmd {
+	return pipeline.tx.ZCount(pipeline.Context, key, min, max)
+}
+
+func (pipeline *redisPipelineCommands) ZRange(key string, start, stop int64) *redis.StringSliceCmd {
+	return pipeline.tx.ZRange(pipeline.Context, key, start, stop)
+}
+
+func (pipeline *redisPipelineCommands) ZRangeWithScores(key string, start, stop int64) *redis.ZSliceCmd {
+	return pipeline.tx.ZRangeWithScores(pipeline.Context, key, start, stop)
+}
+
+func (pipeline *redisPipelineCommands) ZRevRange(key string, start, stop int64) *redis.StringSliceCmd {
+	return pipeline.tx.ZRevRange(pipeline.Context, key, start, stop)
+}
+
+func (pipeline *redisPipelineCommands) ZRevRangeWithScores(key string, start, stop int64) *redis.ZSliceCmd {
+	return pipeline.tx.ZRevRangeWithScores(pipeline.Context, key, start, stop)
+}
+
+func (pipeline *redisPipelineCommands) ZRangeByScore(key string, opt *redis.ZRangeBy) *redis.StringSliceCmd {
+	return pipeline.tx.ZRangeByScore(pipeline.Context, key, opt)
+}
+
+func (pipeline *redisPipelineCommands) ZRevRangeByScore(key string, opt *redis.ZRangeBy) *redis.StringSliceCmd {
+	return pipeline.tx.ZRevRangeByScore(pipeline.Context, key, opt)
+}
+
+func (pipeline *redisPipelineCommands) ZRemRangeByScore(key, min, max string) *redis.IntCmd {
+	return pipeline.tx.ZRemRangeByScore(pipeline.Context, key, min, max)
+}
+
+func (pipeline *redisPipelineCommands) ZRemRangeByRank(key string, start, stop int64) *redis.IntCmd {
+	return pipeline.tx.ZRemRangeByRank(pipeline.Context, key, start, stop)
+}
+
+func (pipeline *redisPipelineCommands) ZScan(key string, cursor uint64, match string, count int64) *redis.ScanCmd {
+	return pipeline.tx.ZScan(pipeline.Context, key, cursor, match, count)
+}
+
+// HyperLogLog
+// ------------------------------------------------------------------------------
+func (pipeline *redisPipelineCommands) PFAdd(key string, els ...interface{}) *redis.IntCmd {
+	return pipeline.tx.PFAdd(pipeline.Context, key, els...)
+}
+
+func (pipeline *redisPipelineCommands) PFCount(keys ...string) *redis.IntCmd {
+	return pipeline.tx.PFCount(pipeline.Context, keys...)
+}
+
+func (pipeline *redisPipelineCommands) PFMerge(dest string, keys ...string) *redis.StatusCmd {
+	return pipeline.tx.PFMerge(pipeline.Context, dest, keys...)
+}
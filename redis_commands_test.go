@@ -0,0 +1,124 @@
+package gm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestRedisCommands(t *testing.T) {
+	redisClient, server := newTestRedisClient(t)
+
+	redisClient.Set("counter", 1, 0)
+	if value, _ := redisClient.IncrBy("counter", 5).Result(); value != 6 {
+		t.Errorf("expected 6, but got %d", value)
+	}
+	redisClient.Expire("counter", time.Minute)
+	if ttl, _ := redisClient.TTL("counter").Result(); ttl != time.Minute {
+		t.Errorf("expected a ttl of 1m, but got %s", ttl)
+	}
+	server.FastForward(time.Minute)
+	if exists, _ := redisClient.Exists("counter").Result(); exists != 0 {
+		t.Error("expected the counter to expire")
+	}
+
+	redisClient.RPush("list", "a", "b", "c")
+	redisClient.LPush("list", "z")
+	if values, _ := redisClient.LRange("list", 0, -1).Result(); fmt.Sprint(values) != "[z a b c]" {
+		t.Errorf("unexpected list %v", values)
+	}
+	if value, _ := redisClient.BLPop(time.Second, "list").Result(); fmt.Sprint(value) != "[list z]" {
+		t.Errorf("unexpected pop %v", value)
+	}
+
+	redisClient.SAdd("set:a", "x", "y")
+	redisClient.SAdd("set:b", "y", "z")
+	if values, _ := redisClient.SInter("set:a", "set:b").Result(); fmt.Sprint(values) != "[y]" {
+		t.Errorf("unexpected intersection %v", values)
+	}
+
+	redisClient.ZAdd("scores", &redis.Z{Score: 10, Member: "alice"}, &redis.Z{Score: 20, Member: "bob"})
+	redisClient.ZIncrBy("scores", 15, "alice")
+	if members, _ := redisClient.ZRevRange("scores", 0, -1).Result(); fmt.Sprint(members) != "[alice bob]" {
+		t.Errorf("unexpected ranking %v", members)
+	}
+
+	redisClient.PFAdd("visitors", "a", "b", "a")
+	if count, _ := redisClient.PFCount("visitors").Result(); count != 2 {
+		t.Errorf("expected 2 visitors, but got %d", count)
+	}
+
+	pipeline := redisClient.NewTxPipeline()
+	incr := pipeline.Incr("tx:counter")
+	pipeline.Expire("tx:counter", time.Hour)
+	pipeline.HIncrBy("tx:hash", "field", 3)
+	card := pipeline.ZCard("scores")
+	if _, err := pipeline.Commit(); err != nil {
+		t.Fatalf("Commit err, msg: %v", err)
+	}
+	if incr.Val() != 1 || card.Val() != 2 {
+		t.Errorf("unexpected pipeline results %d %d", incr.Val(), card.Val())
+	}
+	if ttl, _ := redisClient.TTL("tx:counter").Result(); ttl != time.Hour {
+		t.Errorf("expected a ttl of 1h, but got %s", ttl)
+	}
+}
+
+func collectScan(t *testing.T, iter *RedisScanIterator) []string {
+	t.Helper()
+
+	var values []string
+	for iter.Next() {
+		values = append(values, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		t.Fatalf("scan err, msg: %v", err)
+	}
+	return values
+}
+
+func TestRedisScanIterator(t *testing.T) {
+	redisClient, server := newTestRedisClient(t)
+
+	for i := 0; i < 25; i++ {
+		redisClient.Set(fmt.Sprintf("session:%02d", i), i, 0)
+	}
+	redisClient.Set("other", 1, 0)
+
+	keys := collectScan(t, redisClient.ScanIterator("session:*", 10))
+	if len(keys) != 25 {
+		t.Errorf("expected 25 keys, but got %d", len(keys))
+	}
+
+	redisClient.HSet("hash", "a", "1", "b", "2")
+	fields := collectScan(t, redisClient.HScanIterator("hash", "", 0))
+	if fmt.Sprint(fields) != "[a 1 b 2]" {
+		t.Errorf("unexpected hash scan %v", fields)
+	}
+
+	redisClient.ZAdd("zset", &redis.Z{Score: 1, Member: "m"})
+	if members := collectScan(t, redisClient.ZScanIterator("zset", "", 0)); fmt.Sprint(members) != "[m 1]" {
+		t.Errorf("unexpected sorted set scan %v", members)
+	}
+
+	redisClient.SAdd("set", "x", "y")
+	members := collectScan(t, redisClient.SScanIterator("set", "", 0))
+	sort.Strings(members)
+	if fmt.Sprint(members) != "[x y]" {
+		t.Errorf("unexpected set scan %v", members)
+	}
+
+	// Every master is scanned on a cluster
+	cluster, err := InitRedisCluster(context.Background(), "", "", server.Addr())
+	if err != nil {
+		t.Fatalf("InitRedisCluster err, msg: %v", err)
+	}
+	defer cluster.Close()
+	if keys := collectScan(t, cluster.ScanIterator("session:*", 10)); len(keys) != 25 {
+		t.Errorf("expected 25 keys on the cluster, but got %d", len(keys))
+	}
+}
@@ -0,0 +1,251 @@
+package gm
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mathrand "math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	ErrLockNotObtained = errors.New("redis lock not obtained")
+	ErrLockNotHeld     = errors.New("redis lock not held")
+
+	defaultLockTTL        = 30 * time.Second
+	defaultLockMinBackoff = 50 * time.Millisecond
+	defaultLockMaxBackoff = time.Second
+)
+
+// Only the holder of the token may release or extend the lock
+var (
+	lockReleaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+	lockExtendScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+type LockOptions struct {
+	TTL            time.Duration // lease of the lock, 30 seconds when zero
+	ExtendInterval time.Duration // lease renewal while held, TTL/3 when zero, negative disables renewal
+	MinBackoff     time.Duration // first retry delay of Lock, 50 milliseconds when zero
+	MaxBackoff     time.Duration // retry delay cap of Lock, 1 second when zero
+}
+
+func (options *LockOptions) withDefaults() LockOptions {
+	opts := LockOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = defaultLockTTL
+	}
+	if opts.ExtendInterval == 0 {
+		opts.ExtendInterval = opts.TTL / 3
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = defaultLockMinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(defaultLockMaxBackoff, opts.MinBackoff)
+	}
+	return opts
+}
+
+// A lock held in redis, the lease is renewed in the background until Unlock
+type RedisLock struct {
+	redisClient *RedisClient
+	ctx         context.Context // context of the acquisition without its cancellation, renewals and release outlive a finished request
+	key         string
+	token       string
+	options     LockOptions
+
+	mu       sync.Mutex
+	released bool
+	stop     chan struct{}
+	done     chan struct{}
+	lost     chan struct{}
+}
+
+/*
+Acquire the lock of key without waiting, ErrLockNotObtained when another holder has it
+
+	lock, err := redisClient.TryLock("cron:settlement", &gm.LockOptions{TTL: time.Minute})
+	if errors.Is(err, gm.ErrLockNotObtained) {
+		return // another replica runs the job
+	}
+	defer lock.Unlock()
+*/
+func (redisClient *RedisClient) TryLock(key string, options *LockOptions) (*RedisLock, error) {
+	return redisClient.tryLock(redisClient.Context, key, options.withDefaults())
+}
+
+/*
+Acquire the lock of key, waiting with exponential backoff until it is free or ctx is done
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lock, err := redisClient.Lock(ctx, "nonce:"+address, nil)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	select {
+	case <-lock.Lost():
+		return errors.New("lock lost")
+	default:
+	}
+*/
+func (redisClient *RedisClient) Lock(ctx context.Context, key string, options *LockOptions) (*RedisLock, error) {
+	opts := options.withDefaults()
+	backoff := opts.MinBackoff
+
+	for {
+		lock, err := redisClient.tryLock(ctx, key, opts)
+		if !errors.Is(err, ErrLockNotObtained) {
+			return lock, err
+		}
+
+		// Full jitter keeps waiting replicas from retrying in lockstep
+		timer := time.NewTimer(time.Duration(mathrand.Int64N(int64(backoff)) + 1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("%w %s: %w", ErrLockNotObtained, key, ctx.Err())
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, opts.MaxBackoff)
+	}
+}
+
+func (redisClient *RedisClient) tryLock(ctx context.Context, key string, options LockOptions) (*RedisLock, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	acquiredAt := time.Now()
+	ok, err := redisClient.client.SetNX(ctx, key, token, options.TTL).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis.SetNX %s err %w", key, err)
+	}
+	if !ok {
+		return nil, ErrLockNotObtained
+	}
+
+	lock := &RedisLock{
+		redisClient: redisClient,
+		ctx:         context.WithoutCancel(ctx),
+		key:         key,
+		token:       token,
+		options:     options,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		lost:        make(chan struct{}),
+	}
+
+	if options.ExtendInterval > 0 {
+		go lock.autoExtend(acquiredAt)
+	} else {
+		close(lock.done)
+	}
+	return lock, nil
+}
+
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("rand.Read err %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (lock *RedisLock) Key() string {
+	return lock.key
+}
+
+// Random value identifying this holder
+func (lock *RedisLock) Token() string {
+	return lock.token
+}
+
+// Closed when the lock expired or was taken over, or when renewals kept failing for longer than the TTL
+func (lock *RedisLock) Lost() <-chan struct{} {
+	return lock.lost
+}
+
+// Reset the lease to ttl, ErrLockNotHeld when the lock expired or belongs to another holder
+func (lock *RedisLock) Extend(ttl time.Duration) error {
+	result, err := lockExtendScript.Run(lock.ctx, lock.redisClient.client, []string{lock.key}, lock.token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return fmt.Errorf("redis lock extend %s err %w", lock.key, err)
+	}
+	if result == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// Stop the renewal and release the lock, ErrLockNotHeld when it expired or belongs to another holder
+func (lock *RedisLock) Unlock() error {
+	lock.mu.Lock()
+	if lock.released {
+		lock.mu.Unlock()
+		return ErrLockNotHeld
+	}
+	lock.released = true
+	close(lock.stop)
+	lock.mu.Unlock()
+	<-lock.done
+
+	result, err := lockReleaseScript.Run(lock.ctx, lock.redisClient.client, []string{lock.key}, lock.token).Int64()
+	if err != nil {
+		return fmt.Errorf("redis lock release %s err %w", lock.key, err)
+	}
+	if result == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// Renew the lease every ExtendInterval, renewedAt is when the current lease started
+func (lock *RedisLock) autoExtend(renewedAt time.Time) {
+	defer close(lock.done)
+
+	ticker := time.NewTicker(lock.options.ExtendInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lock.stop:
+			return
+		case <-ticker.C:
+			attemptAt := time.Now()
+			err := lock.Extend(lock.options.TTL)
+			if err == nil {
+				renewedAt = attemptAt
+				continue
+			}
+
+			// Network errors are retried on the next tick while the lease lasts
+			if errors.Is(err, ErrLockNotHeld) || time.Since(renewedAt) >= lock.options.TTL {
+				close(lock.lost)
+				return
+			}
+		}
+	}
+}
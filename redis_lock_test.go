@@ -0,0 +1,164 @@
+package gm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRedisTryLock(t *testing.T) {
+	redisClient, server := newTestRedisClient(t)
+
+	lock, err := redisClient.TryLock("cron:job", &LockOptions{TTL: time.Minute, ExtendInterval: -1})
+	if err != nil {
+		t.Fatalf("TryLock err, msg: %v", err)
+	}
+	if value, _ := server.Get("cron:job"); value != lock.Token() {
+		t.Errorf("expected the token %s to be stored, but got %s", lock.Token(), value)
+	}
+	if ttl := server.TTL("cron:job"); ttl != time.Minute {
+		t.Errorf("expected a ttl of 1m, but got %s", ttl)
+	}
+
+	if _, err := redisClient.TryLock("cron:job", nil); !errors.Is(err, ErrLockNotObtained) {
+		t.Errorf("expected ErrLockNotObtained, but got %v", err)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("Unlock err, msg: %v", err)
+	}
+	if server.Exists("cron:job") {
+		t.Error("expected the lock to be released")
+	}
+	if err := lock.Unlock(); !errors.Is(err, ErrLockNotHeld) {
+		t.Errorf("expected ErrLockNotHeld on a second Unlock, but got %v", err)
+	}
+
+	// After expiry another holder takes over, the stale holder must not release it
+	stale, _ := redisClient.TryLock("cron:job", &LockOptions{TTL: time.Second, ExtendInterval: -1})
+	server.FastForward(2 * time.Second)
+	current, err := redisClient.TryLock("cron:job", &LockOptions{ExtendInterval: -1})
+	if err != nil {
+		t.Fatalf("TryLock after expiry err, msg: %v", err)
+	}
+	if err := stale.Unlock(); !errors.Is(err, ErrLockNotHeld) {
+		t.Errorf("expected ErrLockNotHeld for a stale holder, but got %v", err)
+	}
+	if err := stale.Extend(time.Minute); !errors.Is(err, ErrLockNotHeld) {
+		t.Errorf("expected ErrLockNotHeld for a stale extension, but got %v", err)
+	}
+	if value, _ := server.Get("cron:job"); value != current.Token() {
+		t.Error("expected the current holder to keep the lock")
+	}
+}
+
+func TestRedisLockBlocking(t *testing.T) {
+	redisClient, _ := newTestRedisClient(t)
+
+	held, err := redisClient.TryLock("nonce:0xabc", nil)
+	if err != nil {
+		t.Fatalf("TryLock err, msg: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := redisClient.Lock(ctx, "nonce:0xabc", nil); !errors.Is(err, ErrLockNotObtained) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected ErrLockNotObtained with the context error, but got %v", err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		held.Unlock()
+	}()
+
+	start := time.Now()
+	lock, err := redisClient.Lock(context.Background(), "nonce:0xabc", &LockOptions{MinBackoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Lock err, msg: %v", err)
+	}
+	defer lock.Unlock()
+
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("expected Lock to wait for the release, but returned after %s", elapsed)
+	}
+}
+
+func TestRedisLockAutoExtend(t *testing.T) {
+	redisClient, server := newTestRedisClient(t)
+
+	lock, err := redisClient.TryLock("settlement", &LockOptions{TTL: 300 * time.Millisecond, ExtendInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("TryLock err, msg: %v", err)
+	}
+
+	// miniredis only expires keys on FastForward, the renewal has to restore the lease in between
+	server.FastForward(200 * time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	if ttl := server.TTL("settlement"); ttl <= 100*time.Millisecond {
+		t.Errorf("expected the lease to be renewed, but got a ttl of %s", ttl)
+	}
+
+	select {
+	case <-lock.Lost():
+		t.Fatal("unexpected lost lock")
+	default:
+	}
+
+	server.Del("settlement")
+	select {
+	case <-lock.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("expected the lock to be reported lost")
+	}
+
+	if err := lock.Unlock(); !errors.Is(err, ErrLockNotHeld) {
+		t.Errorf("expected ErrLockNotHeld for a lost lock, but got %v", err)
+	}
+}
+
+func TestRedisLockRequestContext(t *testing.T) {
+	redisClient, server := newTestRedisClient(t)
+
+	// Taken through the view of a request that ends while the lock is held
+	ctx, cancel := context.WithCancel(context.Background())
+	lock, err := redisClient.WithContext(ctx).TryLock("settlement", &LockOptions{TTL: 300 * time.Millisecond, ExtendInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("TryLock err, msg: %v", err)
+	}
+	cancel()
+
+	server.FastForward(200 * time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	if ttl := server.TTL("settlement"); ttl <= 100*time.Millisecond {
+		t.Errorf("expected the lease to be renewed after the request ended, but got a ttl of %s", ttl)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Errorf("Unlock err, msg: %v", err)
+	}
+	if server.Exists("settlement") {
+		t.Error("expected the lock to be released")
+	}
+}
+
+func TestRedisLockRenewalFailure(t *testing.T) {
+	redisClient, server := newTestRedisClient(t)
+
+	lock, err := redisClient.TryLock("settlement", &LockOptions{TTL: 100 * time.Millisecond, ExtendInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("TryLock err, msg: %v", err)
+	}
+
+	// Without redis the lease can not be renewed, it is reported lost once the TTL has passed
+	server.Close()
+	start := time.Now()
+	select {
+	case <-lock.Lost():
+		if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+			t.Errorf("expected the lock to be reported lost after its TTL, but got %s", elapsed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the lock to be reported lost")
+	}
+}
@@ -0,0 +1,323 @@
+package gm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	ErrPubSubClosed = errors.New("redis pubsub is closed")
+
+	defaultPubSubBufferSize = 100
+)
+
+func (redisClient *RedisClient) Publish(channel string, message interface{}) *redis.IntCmd {
+	return redisClient.client.Publish(redisClient.Context, channel, message)
+}
+
+// Publish v encoded as JSON, returns the number of subscribers that received it
+func (redisClient *RedisClient) PublishJSON(channel string, v interface{}) (int64, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0, fmt.Errorf("json.Marshal %v", err)
+	}
+
+	receivers, err := redisClient.Publish(channel, data).Result()
+	if err != nil {
+		return 0, fmt.Errorf("redis.Publish %s err %v", channel, err)
+	}
+	return receivers, nil
+}
+
+// A message decoded from JSON, Err is set when the payload could not be decoded
+type PubSubMessage[T any] struct {
+	Channel string
+	Pattern string // matching pattern of pattern subscriptions
+	Payload T
+	Raw     string
+	Err     error
+}
+
+type pubSubListener struct {
+	names   []string
+	pattern bool
+	removed bool
+	deliver func(message *redis.Message) bool
+	close   func()
+}
+
+/*
+Share a single redis connection between every subscription of the process.
+Channels are subscribed while at least one listener needs them, after a reconnect every channel and pattern is subscribed again.
+*/
+type RedisPubSub struct {
+	redisClient *RedisClient
+	bufferSize  int
+
+	mu       sync.RWMutex
+	pubsub   *redis.PubSub
+	channels map[string]map[*pubSubListener]struct{}
+	patterns map[string]map[*pubSubListener]struct{}
+	closed   bool
+	done     chan struct{}
+	dropped  atomic.Int64
+}
+
+/*
+Create the subscription hub, bufferSize is the number of messages a listener may lag behind before messages are dropped, 100 when zero
+
+	hub := redisClient.NewPubSub(0)
+	defer hub.Close()
+
+	sub, err := gm.Subscribe[OrderEvent](hub, "orders")
+	for message := range sub.C {
+		fmt.Println(message.Payload)
+	}
+*/
+func (redisClient *RedisClient) NewPubSub(bufferSize int) *RedisPubSub {
+	if bufferSize <= 0 {
+		bufferSize = defaultPubSubBufferSize
+	}
+
+	hub := &RedisPubSub{
+		redisClient: redisClient,
+		bufferSize:  bufferSize,
+		pubsub:      redisClient.client.Subscribe(redisClient.Context),
+		channels:    make(map[string]map[*pubSubListener]struct{}),
+		patterns:    make(map[string]map[*pubSubListener]struct{}),
+		done:        make(chan struct{}),
+	}
+
+	go hub.dispatch()
+	return hub
+}
+
+// Messages dropped because a listener did not keep up
+func (hub *RedisPubSub) Dropped() int64 {
+	return hub.dropped.Load()
+}
+
+// Close every subscription and the redis connection
+func (hub *RedisPubSub) Close() error {
+	hub.mu.Lock()
+	if hub.closed {
+		hub.mu.Unlock()
+		return nil
+	}
+	hub.closed = true
+
+	for _, registry := range []map[string]map[*pubSubListener]struct{}{hub.channels, hub.patterns} {
+		for _, listeners := range registry {
+			for listener := range listeners {
+				hub.removeListener(listener)
+				listener.close()
+			}
+		}
+	}
+	hub.mu.Unlock()
+
+	err := hub.pubsub.Close()
+	<-hub.done
+	return err
+}
+
+func (hub *RedisPubSub) dispatch() {
+	defer close(hub.done)
+
+	for message := range hub.pubsub.Channel() {
+		hub.mu.RLock()
+		listeners := hub.channels[message.Channel]
+		if message.Pattern != "" {
+			listeners = hub.patterns[message.Pattern]
+		}
+		for listener := range listeners {
+			if !listener.deliver(message) {
+				hub.dropped.Add(1)
+			}
+		}
+		hub.mu.RUnlock()
+	}
+}
+
+func (hub *RedisPubSub) addListener(listener *pubSubListener) error {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if hub.closed {
+		return ErrPubSubClosed
+	}
+
+	registry := hub.channels
+	if listener.pattern {
+		registry = hub.patterns
+	}
+
+	var subscribe []string
+	for _, name := range listener.names {
+		if _, ok := registry[name]; !ok {
+			subscribe = append(subscribe, name)
+		}
+	}
+
+	if len(subscribe) > 0 {
+		var err error
+		if listener.pattern {
+			err = hub.pubsub.PSubscribe(hub.redisClient.Context, subscribe...)
+		} else {
+			err = hub.pubsub.Subscribe(hub.redisClient.Context, subscribe...)
+		}
+		if err != nil {
+			return fmt.Errorf("redis.Subscribe %v err %v", subscribe, err)
+		}
+	}
+
+	for _, name := range listener.names {
+		if registry[name] == nil {
+			registry[name] = make(map[*pubSubListener]struct{})
+		}
+		registry[name][listener] = struct{}{}
+	}
+	return nil
+}
+
+// Remove the listener, channels without listeners are unsubscribed, called with mu held
+func (hub *RedisPubSub) removeListener(listener *pubSubListener) {
+	registry := hub.channels
+	if listener.pattern {
+		registry = hub.patterns
+	}
+
+	listener.removed = true
+
+	var unsubscribe []string
+	for _, name := range listener.names {
+		delete(registry[name], listener)
+		if len(registry[name]) == 0 {
+			delete(registry, name)
+			unsubscribe = append(unsubscribe, name)
+		}
+	}
+
+	if len(unsubscribe) == 0 || hub.closed {
+		return
+	}
+	if listener.pattern {
+		hub.pubsub.PUnsubscribe(hub.redisClient.Context, unsubscribe...)
+	} else {
+		hub.pubsub.Unsubscribe(hub.redisClient.Context, unsubscribe...)
+	}
+}
+
+// Messages of the subscribed channels or patterns, C is closed by Close
+type Subscription[T any] struct {
+	C <-chan *PubSubMessage[T]
+
+	hub      *RedisPubSub
+	listener *pubSubListener
+	once     sync.Once
+}
+
+// Stop receiving, the channels are unsubscribed when no other subscription uses them
+func (sub *Subscription[T]) Close() {
+	sub.once.Do(func() {
+		sub.hub.mu.Lock()
+		defer sub.hub.mu.Unlock()
+
+		// Already closed by RedisPubSub.Close
+		if sub.listener.removed {
+			return
+		}
+		sub.hub.removeListener(sub.listener)
+		sub.listener.close()
+	})
+}
+
+// Receive JSON messages published on channels
+func Subscribe[T any](hub *RedisPubSub, channels ...string) (*Subscription[T], error) {
+	return subscribe[T](hub, channels, false)
+}
+
+// Receive JSON messages published on channels matching the glob patterns, e.g. "orders.*"
+func PSubscribe[T any](hub *RedisPubSub, patterns ...string) (*Subscription[T], error) {
+	return subscribe[T](hub, patterns, true)
+}
+
+func subscribe[T any](hub *RedisPubSub, names []string, pattern bool) (*Subscription[T], error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("subscription without channels")
+	}
+
+	ch := make(chan *PubSubMessage[T], hub.bufferSize)
+	listener := &pubSubListener{
+		names:   names,
+		pattern: pattern,
+		deliver: func(message *redis.Message) bool {
+			decoded := &PubSubMessage[T]{Channel: message.Channel, Pattern: message.Pattern, Raw: message.Payload}
+			if err := json.Unmarshal([]byte(message.Payload), &decoded.Payload); err != nil {
+				decoded.Err = fmt.Errorf("json.Unmarshal message of %s err %v", message.Channel, err)
+			}
+
+			select {
+			case ch <- decoded:
+				return true
+			default:
+				return false
+			}
+		},
+		close: func() { close(ch) },
+	}
+
+	if err := hub.addListener(listener); err != nil {
+		return nil, err
+	}
+	return &Subscription[T]{C: ch, hub: hub, listener: listener}, nil
+}
+
+/*
+Call handler for every message of channels until ctx is done, returns ctx.Err() or ErrPubSubClosed when the hub is closed
+
+	go gm.SubscribeFunc(ctx, hub, []string{"orders"}, func(message *gm.PubSubMessage[OrderEvent]) {
+		if message.Err != nil {
+			return
+		}
+		process(message.Payload)
+	})
+*/
+func SubscribeFunc[T any](ctx context.Context, hub *RedisPubSub, channels []string, handler func(message *PubSubMessage[T])) error {
+	sub, err := Subscribe[T](hub, channels...)
+	if err != nil {
+		return err
+	}
+	return sub.consume(ctx, handler)
+}
+
+// Pattern variant of SubscribeFunc
+func PSubscribeFunc[T any](ctx context.Context, hub *RedisPubSub, patterns []string, handler func(message *PubSubMessage[T])) error {
+	sub, err := PSubscribe[T](hub, patterns...)
+	if err != nil {
+		return err
+	}
+	return sub.consume(ctx, handler)
+}
+
+func (sub *Subscription[T]) consume(ctx context.Context, handler func(message *PubSubMessage[T])) error {
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case message, ok := <-sub.C:
+			if !ok {
+				return ErrPubSubClosed
+			}
+			handler(message)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package gm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type testPubSubEvent struct {
+	OrderID int64
+	Status  string
+}
+
+func waitForSubscribers(t *testing.T, redisClient *RedisClient, channel string, count int64) {
+	t.Helper()
+
+	waitForCondition(t, func() bool {
+		subscribers, _ := redisClient.Client().PubSubNumSub(context.Background(), channel).Result()
+		return subscribers[channel] == count
+	})
+}
+
+func receiveMessage[T any](t *testing.T, sub *Subscription[T]) *PubSubMessage[T] {
+	t.Helper()
+
+	select {
+	case message := <-sub.C:
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a message")
+		return nil
+	}
+}
+
+func TestRedisPubSubFanOut(t *testing.T) {
+	redisClient, _ := newTestRedisClient(t)
+	hub := redisClient.NewPubSub(0)
+	defer hub.Close()
+
+	first, err := Subscribe[testPubSubEvent](hub, "orders")
+	if err != nil {
+		t.Fatalf("Subscribe err, msg: %v", err)
+	}
+	second, _ := Subscribe[testPubSubEvent](hub, "orders")
+	pattern, err := PSubscribe[testPubSubEvent](hub, "orders*")
+	if err != nil {
+		t.Fatalf("PSubscribe err, msg: %v", err)
+	}
+
+	// Both listeners share one redis subscription
+	waitForSubscribers(t, redisClient, "orders", 1)
+
+	if receivers, err := redisClient.PublishJSON("orders", testPubSubEvent{OrderID: 7, Status: "paid"}); err != nil || receivers != 2 {
+		t.Fatalf("PublishJSON expected 2 receivers, but got %d %v", receivers, err)
+	}
+	for _, sub := range []*Subscription[testPubSubEvent]{first, second, pattern} {
+		message := receiveMessage(t, sub)
+		if message.Err != nil || message.Payload.OrderID != 7 || message.Channel != "orders" {
+			t.Errorf("unexpected message %+v", message)
+		}
+	}
+
+	redisClient.Publish("orders.eu", "not json")
+	message := receiveMessage(t, pattern)
+	if message.Pattern != "orders*" || message.Channel != "orders.eu" || message.Err == nil || message.Raw != "not json" {
+		t.Errorf("expected a decode error on the pattern subscription, but got %+v", message)
+	}
+
+	// The redis subscription stays until the last listener leaves
+	first.Close()
+	if _, ok := <-first.C; ok {
+		t.Error("expected the channel to be closed")
+	}
+	waitForSubscribers(t, redisClient, "orders", 1)
+	second.Close()
+	waitForSubscribers(t, redisClient, "orders", 0)
+}
+
+func TestRedisPubSubFunc(t *testing.T) {
+	redisClient, _ := newTestRedisClient(t)
+	hub := redisClient.NewPubSub(0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	received := make(chan string, 1)
+	result := make(chan error)
+	go func() {
+		result <- SubscribeFunc(ctx, hub, []string{"events"}, func(message *PubSubMessage[string]) {
+			received <- message.Payload
+		})
+	}()
+
+	waitForSubscribers(t, redisClient, "events", 1)
+	redisClient.PublishJSON("events", "hello")
+	if payload := <-received; payload != "hello" {
+		t.Errorf("expected hello, but got %s", payload)
+	}
+
+	cancel()
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, but got %v", err)
+	}
+	waitForSubscribers(t, redisClient, "events", 0)
+
+	go func() {
+		result <- SubscribeFunc(context.Background(), hub, []string{"events"}, func(*PubSubMessage[string]) {})
+	}()
+	waitForSubscribers(t, redisClient, "events", 1)
+	hub.Close()
+	if err := <-result; !errors.Is(err, ErrPubSubClosed) {
+		t.Errorf("expected ErrPubSubClosed, but got %v", err)
+	}
+	if _, err := Subscribe[string](hub, "events"); !errors.Is(err, ErrPubSubClosed) {
+		t.Errorf("expected ErrPubSubClosed, but got %v", err)
+	}
+}
+
+func TestRedisPubSubReconnect(t *testing.T) {
+	redisClient, server := newTestRedisClient(t)
+	hub := redisClient.NewPubSub(0)
+	defer hub.Close()
+
+	sub, _ := Subscribe[int](hub, "ticks")
+	waitForSubscribers(t, redisClient, "ticks", 1)
+
+	// Restarting drops every connection, the hub subscribes again on its new connection
+	server.Close()
+	if err := server.Restart(); err != nil {
+		t.Fatalf("Restart err, msg: %v", err)
+	}
+
+	waitForCondition(t, func() bool {
+		redisClient.PublishJSON("ticks", 1)
+		select {
+		case message := <-sub.C:
+			return message.Payload == 1
+		default:
+			return false
+		}
+	})
+}
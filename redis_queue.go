@@ -0,0 +1,546 @@
+package gm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+var (
+	defaultQueueGroup             = "workers"
+	defaultQueueConcurrency       = 1
+	defaultQueueMaxAttempts       = 5
+	defaultQueueMinBackoff        = time.Second
+	defaultQueueMaxBackoff        = 5 * time.Minute
+	defaultQueueVisibilityTimeout = 5 * time.Minute
+	defaultQueueBlockTimeout      = 2 * time.Second
+	defaultQueuePollInterval      = time.Second
+	defaultQueueShutdownTimeout   = 30 * time.Second
+	queuePromoteBatch             = 100
+)
+
+/*
+Move due members of the delayed sorted set KEYS[1] to the stream KEYS[2].
+ARGV: now in milliseconds and the batch size, members are JSON objects of string fields.
+*/
+var queuePromoteScript = redis.NewScript(`
+local due = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, tonumber(ARGV[2]))
+for _, member in ipairs(due) do
+	local job = cjson.decode(member)
+	redis.call("XADD", KEYS[2], "*", "payload", job.payload, "attempt", job.attempt, "enqueued", job.enqueued)
+	redis.call("ZREM", KEYS[1], member)
+end
+return #due`)
+
+type QueueOptions struct {
+	Group             string        // consumer group, "workers" when empty
+	Consumer          string        // consumer name within the group, host name and process id when empty
+	Concurrency       int           // jobs handled in parallel by Run, 1 when zero
+	MaxAttempts       int           // attempts before a job is moved to the dead-letter stream, 5 when zero
+	MinBackoff        time.Duration // delay before the second attempt, doubled for every further attempt, 1 second when zero
+	MaxBackoff        time.Duration // retry delay cap, 5 minutes when zero
+	VisibilityTimeout time.Duration // jobs unacknowledged for longer are reclaimed from stopped workers, running jobs are kept by a heartbeat, 5 minutes when zero
+	BlockTimeout      time.Duration // wait of every stream read, bounds how fast Run notices shutdown, 2 seconds when zero
+	PollInterval      time.Duration // check of delayed and stuck jobs, 1 second when zero
+	ShutdownTimeout   time.Duration // wait for running jobs after shutdown before their context is cancelled, 30 seconds when zero
+}
+
+// A job read from the queue
+type Job[T any] struct {
+	ID         string // stream message id
+	Payload    T
+	Attempt    int // 1 for the first run
+	EnqueuedAt time.Time
+	LastError  string // failure of the last attempt, set on dead letters
+}
+
+type JobHandler[T any] func(ctx context.Context, job *Job[T]) error
+
+// Durable queue of T payloads on a redis stream, with retries, delayed jobs and a dead-letter stream
+type RedisQueue[T any] struct {
+	redisClient *RedisClient
+	name        string
+	options     QueueOptions
+
+	// Jobs running in this process, kept from being reclaimed by their heartbeat
+	runningMu sync.Mutex
+	running   map[string]struct{}
+
+	now func() time.Time
+}
+
+// Delayed jobs are kept as JSON in a sorted set until due, all fields are strings for the promote script
+type queueDelayedJob struct {
+	ID       string `json:"id"`
+	Payload  string `json:"payload"`
+	Attempt  string `json:"attempt"`
+	Enqueued string `json:"enqueued"`
+}
+
+/*
+Create a queue on the stream name, delayed jobs are kept in "<name>:delayed" and dead letters in "<name>:dead".
+On a redis cluster put the name in a hash tag, e.g. "{jobs:transfer}", so the three keys live in the same slot.
+
+	type Transfer struct {
+		To     string
+		Amount string
+	}
+
+	queue := gm.NewRedisQueue[Transfer](redisClient, "jobs:transfer", &gm.QueueOptions{Concurrency: 4})
+	queue.Enqueue(Transfer{To: "0xabc", Amount: "1.5"})
+
+	err := queue.Run(ctx, func(ctx context.Context, job *gm.Job[Transfer]) error {
+		return send(ctx, job.Payload)
+	})
+*/
+func NewRedisQueue[T any](redisClient *RedisClient, name string, options *QueueOptions) *RedisQueue[T] {
+	opts := QueueOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Group == "" {
+		opts.Group = defaultQueueGroup
+	}
+	if opts.Consumer == "" {
+		host, _ := os.Hostname()
+		opts.Consumer = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultQueueConcurrency
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultQueueMaxAttempts
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = defaultQueueMinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(defaultQueueMaxBackoff, opts.MinBackoff)
+	}
+	if opts.VisibilityTimeout <= 0 {
+		opts.VisibilityTimeout = defaultQueueVisibilityTimeout
+	}
+	if opts.BlockTimeout <= 0 {
+		opts.BlockTimeout = defaultQueueBlockTimeout
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultQueuePollInterval
+	}
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = defaultQueueShutdownTimeout
+	}
+
+	return &RedisQueue[T]{redisClient: redisClient, name: name, options: opts, running: map[string]struct{}{}, now: time.Now}
+}
+
+func (queue *RedisQueue[T]) Name() string {
+	return queue.name
+}
+
+func (queue *RedisQueue[T]) DelayedKey() string {
+	return queue.name + ":delayed"
+}
+
+func (queue *RedisQueue[T]) DeadLetterKey() string {
+	return queue.name + ":dead"
+}
+
+// Add a job for immediate processing, returns the stream message id
+func (queue *RedisQueue[T]) Enqueue(payload T) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal %v", err)
+	}
+
+	id, err := queue.redisClient.client.XAdd(queue.redisClient.Context, &redis.XAddArgs{
+		Stream: queue.name,
+		Values: []interface{}{"payload", string(data), "attempt", 1, "enqueued", queue.now().UnixMilli()},
+	}).Result()
+	if err != nil {
+		return "", fmt.Errorf("redis.XAdd %s err %v", queue.name, err)
+	}
+	return id, nil
+}
+
+// Add a job processed once delay has passed
+func (queue *RedisQueue[T]) EnqueueIn(payload T, delay time.Duration) error {
+	return queue.EnqueueAt(payload, queue.now().Add(delay))
+}
+
+// Add a job processed once at is reached
+func (queue *RedisQueue[T]) EnqueueAt(payload T, at time.Time) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("json.Marshal %v", err)
+	}
+
+	return queue.schedule(queue.redisClient.client, string(data), 1, queue.now(), at)
+}
+
+func (queue *RedisQueue[T]) schedule(cmd redis.Cmdable, payload string, attempt int, enqueuedAt, at time.Time) error {
+	member, err := json.Marshal(queueDelayedJob{
+		ID:       uuid.NewString(),
+		Payload:  payload,
+		Attempt:  strconv.Itoa(attempt),
+		Enqueued: strconv.FormatInt(enqueuedAt.UnixMilli(), 10),
+	})
+	if err != nil {
+		return fmt.Errorf("json.Marshal %v", err)
+	}
+
+	if err := cmd.ZAdd(queue.redisClient.Context, queue.DelayedKey(), &redis.Z{Score: float64(at.UnixMilli()), Member: member}).Err(); err != nil {
+		return fmt.Errorf("redis.ZAdd %s err %v", queue.DelayedKey(), err)
+	}
+	return nil
+}
+
+// Jobs waiting in the stream, delayed jobs not yet due are not counted
+func (queue *RedisQueue[T]) Len() (int64, error) {
+	return queue.redisClient.client.XLen(queue.redisClient.Context, queue.name).Result()
+}
+
+// Oldest count jobs of the dead-letter stream, with the error of their last attempt
+func (queue *RedisQueue[T]) DeadLetters(count int64) ([]*Job[T], error) {
+	messages, err := queue.redisClient.client.XRangeN(queue.redisClient.Context, queue.DeadLetterKey(), "-", "+", count).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis.XRange %s err %v", queue.DeadLetterKey(), err)
+	}
+
+	jobs := make([]*Job[T], 0, len(messages))
+	for _, message := range messages {
+		job, err := queue.decode(message)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+/*
+Process jobs with handler until ctx is done.
+Jobs are acknowledged when handler returns nil, failures are retried with exponential backoff
+and moved to the dead-letter stream after MaxAttempts.
+On shutdown no new jobs are read and running jobs get ShutdownTimeout to finish,
+jobs interrupted after that are reclaimed once VisibilityTimeout has passed and count as a failed attempt.
+*/
+func (queue *RedisQueue[T]) Run(ctx context.Context, handler JobHandler[T]) error {
+	err := queue.redisClient.client.XGroupCreateMkStream(queue.redisClient.Context, queue.name, queue.options.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("redis.XGroupCreate %s err %v", queue.name, err)
+	}
+
+	// Running jobs outlive ctx by up to ShutdownTimeout
+	jobCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < queue.options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			queue.work(ctx, jobCtx, handler)
+		}()
+	}
+
+	maintenanceDone := make(chan struct{})
+	go func() {
+		defer close(maintenanceDone)
+		queue.maintain(ctx)
+	}()
+
+	// The heartbeat keeps running through the shutdown, until the last job returns
+	heartbeatCtx, stopHeartbeat := context.WithCancel(context.Background())
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		queue.keepAlive(heartbeatCtx)
+	}()
+	defer func() {
+		stopHeartbeat()
+		<-heartbeatDone
+	}()
+
+	<-ctx.Done()
+	<-maintenanceDone
+
+	workersDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(workersDone)
+	}()
+
+	timer := time.NewTimer(queue.options.ShutdownTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-workersDone:
+	case <-timer.C:
+		cancelJobs()
+		<-workersDone
+	}
+	return ctx.Err()
+}
+
+func (queue *RedisQueue[T]) work(ctx, jobCtx context.Context, handler JobHandler[T]) {
+	for ctx.Err() == nil {
+		streams, err := queue.redisClient.client.XReadGroup(queue.redisClient.Context, &redis.XReadGroupArgs{
+			Group:    queue.options.Group,
+			Consumer: queue.options.Consumer,
+			Streams:  []string{queue.name, ">"},
+			Count:    1,
+			Block:    queue.options.BlockTimeout,
+		}).Result()
+		if err != nil {
+			if !errors.Is(err, redis.Nil) {
+				queue.sleep(ctx, queue.options.PollInterval)
+			}
+			continue
+		}
+
+		for _, stream := range streams {
+			for _, message := range stream.Messages {
+				queue.process(jobCtx, message, handler)
+			}
+		}
+	}
+}
+
+// Promote due delayed jobs and reclaim jobs idle for longer than VisibilityTimeout
+func (queue *RedisQueue[T]) maintain(ctx context.Context) {
+	ticker := time.NewTicker(queue.options.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		queue.promote()
+
+		messages, err := queue.autoClaim()
+		if err == nil {
+			// The worker holding the job stopped during its attempt, which counts as a failed attempt
+			for _, message := range messages {
+				// Idle past the timeout between two heartbeats, the job still runs here
+				if queue.isRunning(message.ID) {
+					continue
+				}
+
+				job, err := queue.decode(message)
+				if err != nil {
+					queue.fail(message, nil, queue.options.MaxAttempts, err)
+					continue
+				}
+				queue.fail(message, job, job.Attempt, fmt.Errorf("job not acknowledged within %s", queue.options.VisibilityTimeout))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Send a heartbeat for the running jobs every PollInterval until ctx is done
+func (queue *RedisQueue[T]) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(queue.options.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			queue.heartbeat()
+		}
+	}
+}
+
+// Reset the idle time of the running jobs so no worker reclaims them while their handler runs
+func (queue *RedisQueue[T]) heartbeat() {
+	queue.runningMu.Lock()
+	ids := make([]string, 0, len(queue.running))
+	for id := range queue.running {
+		ids = append(ids, id)
+	}
+	queue.runningMu.Unlock()
+
+	if len(ids) == 0 {
+		return
+	}
+	queue.redisClient.client.XClaimJustID(queue.redisClient.Context, &redis.XClaimArgs{
+		Stream:   queue.name,
+		Group:    queue.options.Group,
+		Consumer: queue.options.Consumer,
+		Messages: ids,
+	})
+}
+
+func (queue *RedisQueue[T]) isRunning(id string) bool {
+	queue.runningMu.Lock()
+	defer queue.runningMu.Unlock()
+	_, ok := queue.running[id]
+	return ok
+}
+
+/*
+Claim jobs idle for longer than VisibilityTimeout.
+XAutoClaim of go-redis v8 expects the two element reply of Redis 6.2 and fails on the three elements of Redis 7, the reply is parsed here.
+*/
+func (queue *RedisQueue[T]) autoClaim() ([]redis.XMessage, error) {
+	reply, err := queue.redisClient.client.Do(queue.redisClient.Context, "XAUTOCLAIM", queue.name, queue.options.Group, queue.options.Consumer,
+		queue.options.VisibilityTimeout.Milliseconds(), "0-0", "COUNT", queuePromoteBatch).Slice()
+	if err != nil {
+		return nil, fmt.Errorf("redis.XAutoClaim %s err %v", queue.name, err)
+	}
+	if len(reply) < 2 {
+		return nil, fmt.Errorf("redis.XAutoClaim %s unexpected reply %v", queue.name, reply)
+	}
+
+	entries, _ := reply[1].([]interface{})
+	messages := make([]redis.XMessage, 0, len(entries))
+	for _, entry := range entries {
+		// Entries deleted while pending are returned as nil by Redis 6.2
+		fields, ok := entry.([]interface{})
+		if !ok || len(fields) != 2 {
+			continue
+		}
+
+		id, _ := fields[0].(string)
+		values, _ := fields[1].([]interface{})
+		message := redis.XMessage{ID: id, Values: make(map[string]interface{}, len(values)/2)}
+		for i := 0; i+1 < len(values); i += 2 {
+			key, _ := values[i].(string)
+			message.Values[key] = values[i+1]
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func (queue *RedisQueue[T]) promote() {
+	for {
+		promoted, err := queuePromoteScript.Run(queue.redisClient.Context, queue.redisClient.client,
+			[]string{queue.DelayedKey(), queue.name}, queue.now().UnixMilli(), queuePromoteBatch).Int()
+		if err != nil || promoted < queuePromoteBatch {
+			return
+		}
+	}
+}
+
+func (queue *RedisQueue[T]) process(ctx context.Context, message redis.XMessage, handler JobHandler[T]) {
+	queue.runningMu.Lock()
+	queue.running[message.ID] = struct{}{}
+	queue.runningMu.Unlock()
+	defer func() {
+		queue.runningMu.Lock()
+		delete(queue.running, message.ID)
+		queue.runningMu.Unlock()
+	}()
+
+	job, err := queue.decode(message)
+	if err != nil {
+		// Undecodable payloads can not succeed on retry
+		queue.fail(message, nil, queue.options.MaxAttempts, err)
+		return
+	}
+
+	if err := queue.handle(ctx, job, handler); err != nil {
+		queue.fail(message, job, job.Attempt, err)
+		return
+	}
+
+	pipeline := queue.redisClient.client.TxPipeline()
+	pipeline.XAck(queue.redisClient.Context, queue.name, queue.options.Group, message.ID)
+	pipeline.XDel(queue.redisClient.Context, queue.name, message.ID)
+	pipeline.Exec(queue.redisClient.Context)
+}
+
+func (queue *RedisQueue[T]) handle(ctx context.Context, job *Job[T], handler JobHandler[T]) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panic: %v", r)
+		}
+	}()
+
+	return handler(ctx, job)
+}
+
+// Schedule the next attempt, or move the job to the dead-letter stream when attempts are exhausted
+func (queue *RedisQueue[T]) fail(message redis.XMessage, job *Job[T], attempt int, cause error) {
+	ctx := queue.redisClient.Context
+	payload, _ := message.Values["payload"].(string)
+
+	pipeline := queue.redisClient.client.TxPipeline()
+	if attempt >= queue.options.MaxAttempts {
+		pipeline.XAdd(ctx, &redis.XAddArgs{
+			Stream: queue.DeadLetterKey(),
+			Values: []interface{}{
+				"payload", payload,
+				"attempt", attempt,
+				"enqueued", message.Values["enqueued"],
+				"error", cause.Error(),
+				"source", message.ID,
+			},
+		})
+	} else {
+		enqueuedAt := queue.now()
+		if job != nil {
+			enqueuedAt = job.EnqueuedAt
+		}
+		if err := queue.schedule(pipeline, payload, attempt+1, enqueuedAt, queue.now().Add(queue.backoff(attempt))); err != nil {
+			return
+		}
+	}
+	pipeline.XAck(ctx, queue.name, queue.options.Group, message.ID)
+	pipeline.XDel(ctx, queue.name, message.ID)
+	pipeline.Exec(ctx)
+}
+
+// Delay after the failure of attempt, MinBackoff doubled for every earlier failure
+func (queue *RedisQueue[T]) backoff(attempt int) time.Duration {
+	delay := queue.options.MinBackoff
+	for i := 1; i < attempt && delay < queue.options.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, queue.options.MaxBackoff)
+}
+
+func (queue *RedisQueue[T]) decode(message redis.XMessage) (*Job[T], error) {
+	job := &Job[T]{ID: message.ID, Attempt: 1}
+
+	if attempt, err := strconv.Atoi(fmt.Sprint(message.Values["attempt"])); err == nil {
+		job.Attempt = attempt
+	}
+	if enqueued, err := strconv.ParseInt(fmt.Sprint(message.Values["enqueued"]), 10, 64); err == nil {
+		job.EnqueuedAt = time.UnixMilli(enqueued)
+	}
+	if lastError, ok := message.Values["error"].(string); ok {
+		job.LastError = lastError
+	}
+
+	payload, _ := message.Values["payload"].(string)
+	if err := json.Unmarshal([]byte(payload), &job.Payload); err != nil {
+		return job, fmt.Errorf("json.Unmarshal job %s payload err %v", message.ID, err)
+	}
+	return job, nil
+}
+
+func (queue *RedisQueue[T]) sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
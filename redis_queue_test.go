@@ -0,0 +1,230 @@
+package gm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type testQueueJob struct {
+	Name string
+}
+
+func newTestQueueOptions() *QueueOptions {
+	return &QueueOptions{
+		Concurrency:       3,
+		MaxAttempts:       3,
+		MinBackoff:        10 * time.Millisecond,
+		MaxBackoff:        20 * time.Millisecond,
+		VisibilityTimeout: time.Minute,
+		BlockTimeout:      20 * time.Millisecond,
+		PollInterval:      10 * time.Millisecond,
+	}
+}
+
+func waitForCondition(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRedisQueue(t *testing.T) {
+	redisClient, _ := newTestRedisClient(t)
+	queue := NewRedisQueue[testQueueJob](redisClient, "jobs", newTestQueueOptions())
+
+	for _, name := range []string{"a", "b", "flaky", "broken"} {
+		if _, err := queue.Enqueue(testQueueJob{Name: name}); err != nil {
+			t.Fatalf("Enqueue err, msg: %v", err)
+		}
+	}
+	if err := queue.EnqueueIn(testQueueJob{Name: "delayed"}, 100*time.Millisecond); err != nil {
+		t.Fatalf("EnqueueIn err, msg: %v", err)
+	}
+	enqueuedAt := time.Now()
+
+	var mu sync.Mutex
+	done := map[string]int{}
+	var delayedAt time.Time
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error)
+	go func() {
+		result <- queue.Run(ctx, func(ctx context.Context, job *Job[testQueueJob]) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch job.Payload.Name {
+			case "flaky":
+				if job.Attempt == 1 {
+					return errors.New("temporary failure")
+				}
+			case "broken":
+				panic("always broken")
+			case "delayed":
+				delayedAt = time.Now()
+			}
+			done[job.Payload.Name] = job.Attempt
+			return nil
+		})
+	}()
+
+	waitForCondition(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(done) == 4
+	})
+	waitForCondition(t, func() bool {
+		dead, _ := queue.DeadLetters(10)
+		return len(dead) == 1
+	})
+	cancel()
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, but got %v", err)
+	}
+
+	expected := map[string]int{"a": 1, "b": 1, "flaky": 2, "delayed": 1}
+	for name, attempt := range expected {
+		if done[name] != attempt {
+			t.Errorf("expected %s to succeed on attempt %d, but got %d", name, attempt, done[name])
+		}
+	}
+	if delayedAt.Sub(enqueuedAt) < 100*time.Millisecond {
+		t.Errorf("expected the delayed job to wait 100ms, but ran after %s", delayedAt.Sub(enqueuedAt))
+	}
+
+	dead, _ := queue.DeadLetters(10)
+	if dead[0].Payload.Name != "broken" || dead[0].Attempt != 3 || dead[0].LastError != "job panic: always broken" {
+		t.Errorf("unexpected dead letter %+v", dead[0])
+	}
+
+	if length, _ := queue.Len(); length != 0 {
+		t.Errorf("expected processed jobs to be removed from the stream, but %d remain", length)
+	}
+	if delayed, _ := redisClient.Client().ZCard(context.Background(), queue.DelayedKey()).Result(); delayed != 0 {
+		t.Errorf("expected no delayed jobs left, but got %d", delayed)
+	}
+}
+
+func TestRedisQueueReclaim(t *testing.T) {
+	redisClient, _ := newTestRedisClient(t)
+	options := newTestQueueOptions()
+	options.VisibilityTimeout = 50 * time.Millisecond
+	queue := NewRedisQueue[testQueueJob](redisClient, "jobs", options)
+	ctx := context.Background()
+
+	// A worker that stopped after reading the job, without acknowledging it
+	queue.Enqueue(testQueueJob{Name: "stuck"})
+	redisClient.Client().XGroupCreateMkStream(ctx, "jobs", "workers", "0")
+	redisClient.Client().XReadGroup(ctx, &redis.XReadGroupArgs{Group: "workers", Consumer: "crashed", Streams: []string{"jobs", ">"}, Count: 1, Block: -1})
+
+	attempts := make(chan int, 1)
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go queue.Run(runCtx, func(ctx context.Context, job *Job[testQueueJob]) error {
+		attempts <- job.Attempt
+		return nil
+	})
+
+	select {
+	case attempt := <-attempts:
+		if attempt != 2 {
+			t.Errorf("expected the reclaimed job to run as attempt 2, but got %d", attempt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the reclaimed job")
+	}
+}
+
+func TestRedisQueueLongJob(t *testing.T) {
+	redisClient, _ := newTestRedisClient(t)
+	newReplica := func(consumer string) *RedisQueue[testQueueJob] {
+		options := newTestQueueOptions()
+		options.Consumer = consumer
+		options.VisibilityTimeout = 50 * time.Millisecond
+		return NewRedisQueue[testQueueJob](redisClient, "jobs", options)
+	}
+
+	// Two replicas on the same stream, neither may reclaim the job the other one is running
+	first, second := newReplica("first"), newReplica("second")
+	first.Enqueue(testQueueJob{Name: "long"})
+
+	var mu sync.Mutex
+	var attempts []int
+	handler := func(ctx context.Context, job *Job[testQueueJob]) error {
+		mu.Lock()
+		attempts = append(attempts, job.Attempt)
+		mu.Unlock()
+		time.Sleep(300 * time.Millisecond)
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go first.Run(ctx, handler)
+	go second.Run(ctx, handler)
+
+	waitForCondition(t, func() bool {
+		length, _ := first.Len()
+		return length == 0
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(attempts) != 1 || attempts[0] != 1 {
+		t.Errorf("expected the long job to run once, but got attempts %v", attempts)
+	}
+	if dead, _ := first.DeadLetters(10); len(dead) != 0 {
+		t.Errorf("expected no dead letters, but got %d", len(dead))
+	}
+	if delayed, _ := redisClient.Client().ZCard(context.Background(), first.DelayedKey()).Result(); delayed != 0 {
+		t.Errorf("expected no retry to be scheduled, but got %d", delayed)
+	}
+}
+
+func TestRedisQueueShutdown(t *testing.T) {
+	redisClient, _ := newTestRedisClient(t)
+	queue := NewRedisQueue[testQueueJob](redisClient, "jobs", newTestQueueOptions())
+	queue.Enqueue(testQueueJob{Name: "slow"})
+
+	started, finished := make(chan struct{}), make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error)
+	go func() {
+		result <- queue.Run(ctx, func(ctx context.Context, job *Job[testQueueJob]) error {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			close(finished)
+			return nil
+		})
+	}()
+
+	<-started
+	cancel()
+	<-result
+
+	// Run returns only after the running job completed with a live context
+	select {
+	case <-finished:
+	default:
+		t.Fatal("expected the running job to finish before Run returned")
+	}
+
+	pending, _ := redisClient.Client().XPending(context.Background(), "jobs", "workers").Result()
+	if pending.Count != 0 {
+		t.Errorf("expected the finished job to be acknowledged, but %d are pending", pending.Count)
+	}
+}
@@ -0,0 +1,173 @@
+package gm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Outcome of a rate limited request
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int64         // requests allowed per window, or the bucket size
+	Remaining  int64         // requests left right now
+	RetryAfter time.Duration // wait before the next request is allowed, zero when allowed
+	ResetAfter time.Duration // wait until the limit is fully restored
+}
+
+type RateLimiter interface {
+	// Count a request of key, ctx bounds the call to redis, usually the context of the request
+	Allow(ctx context.Context, key string) (*RateLimitResult, error)
+}
+
+/*
+KEYS[1] sorted set of request timestamps, ARGV: now and window in milliseconds, limit, unique member.
+Returns allowed, remaining, retry after and reset after in milliseconds.
+*/
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+
+local allowed = 0
+if count < limit then
+	redis.call("ZADD", KEYS[1], now, ARGV[4])
+	redis.call("PEXPIRE", KEYS[1], window)
+	count = count + 1
+	allowed = 1
+end
+
+local retry_after = 0
+local reset_after = 0
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+if #oldest > 0 then
+	reset_after = tonumber(oldest[2]) + window - now
+	if allowed == 0 then
+		retry_after = reset_after
+	end
+end
+
+return {allowed, limit - count, retry_after, reset_after}`)
+
+/*
+KEYS[1] hash of the tokens left and the last refill, ARGV: now in milliseconds, tokens per second, bucket size.
+Returns allowed, remaining, retry after and reset after in milliseconds.
+*/
+var tokenBucketScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local burst = tonumber(ARGV[3])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+
+local allowed = 0
+local retry_after = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry_after = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+local reset_after = math.ceil((burst - tokens) * 1000 / rate)
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.max(reset_after, 1))
+
+return {allowed, math.floor(tokens), retry_after, reset_after}`)
+
+// Allow at most Limit requests within any Window, shared by every replica using the same redis
+type SlidingWindowLimiter struct {
+	redisClient *RedisClient
+	Prefix      string
+	Limit       int64
+	Window      time.Duration
+
+	now func() time.Time
+}
+
+/*
+Create a limiter keeping the request timestamps of every key in a sorted set, timestamps come from the local clock
+
+	limiter := redisClient.NewSlidingWindowLimiter("ratelimit:login:", 5, time.Minute)
+	result, err := limiter.Allow(ctx, ip)
+*/
+func (redisClient *RedisClient) NewSlidingWindowLimiter(prefix string, limit int64, window time.Duration) *SlidingWindowLimiter {
+	return &SlidingWindowLimiter{redisClient: redisClient, Prefix: prefix, Limit: limit, Window: window, now: time.Now}
+}
+
+func (limiter *SlidingWindowLimiter) Allow(ctx context.Context, key string) (*RateLimitResult, error) {
+	now := limiter.now()
+	member := strconv.FormatInt(now.UnixNano(), 36) + ":" + strconv.FormatUint(rand.Uint64(), 36)
+
+	values, err := slidingWindowScript.Run(ctx, limiter.redisClient.client, []string{limiter.Prefix + key},
+		now.UnixMilli(), limiter.Window.Milliseconds(), limiter.Limit, member).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("redis sliding window %s err %w", key, err)
+	}
+
+	return newRateLimitResult(values, limiter.Limit), nil
+}
+
+// Allow bursts of Burst requests refilled at Rate requests per second, shared by every replica using the same redis
+type TokenBucketLimiter struct {
+	redisClient *RedisClient
+	Prefix      string
+	Rate        float64
+	Burst       int64
+
+	now func() time.Time
+}
+
+/*
+Create a limiter keeping a token bucket of every key in a hash, refills are computed from the local clock
+
+	limiter := redisClient.NewTokenBucketLimiter("ratelimit:api:", 10, 20)
+	result, err := limiter.Allow(ctx, subject)
+*/
+func (redisClient *RedisClient) NewTokenBucketLimiter(prefix string, rate float64, burst int64) *TokenBucketLimiter {
+	return &TokenBucketLimiter{redisClient: redisClient, Prefix: prefix, Rate: rate, Burst: burst, now: time.Now}
+}
+
+func (limiter *TokenBucketLimiter) Allow(ctx context.Context, key string) (*RateLimitResult, error) {
+	if limiter.Rate <= 0 || math.IsInf(limiter.Rate, 0) {
+		return nil, fmt.Errorf("invalid token bucket rate %v", limiter.Rate)
+	}
+
+	values, err := tokenBucketScript.Run(ctx, limiter.redisClient.client, []string{limiter.Prefix + key},
+		limiter.now().UnixMilli(), strconv.FormatFloat(limiter.Rate, 'f', -1, 64), limiter.Burst).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("redis token bucket %s err %w", key, err)
+	}
+
+	return newRateLimitResult(values, limiter.Burst), nil
+}
+
+func newRateLimitResult(values []int64, limit int64) *RateLimitResult {
+	if len(values) != 4 {
+		return &RateLimitResult{Limit: limit}
+	}
+
+	return &RateLimitResult{
+		Allowed:    values[0] == 1,
+		Limit:      limit,
+		Remaining:  max(values[1], 0),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		ResetAfter: time.Duration(values[3]) * time.Millisecond,
+	}
+}
@@ -0,0 +1,93 @@
+package gm
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestSlidingWindowLimiter(t *testing.T) {
+	redisClient, _ := newTestRedisClient(t)
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	limiter := redisClient.NewSlidingWindowLimiter("rl:", 3, time.Minute)
+	limiter.now = clock.Now
+
+	for i := 0; i < 3; i++ {
+		result, err := limiter.Allow(context.Background(), "1.2.3.4")
+		if err != nil {
+			t.Fatalf("Allow err, msg: %v", err)
+		}
+		if !result.Allowed || result.Remaining != int64(2-i) || result.Limit != 3 {
+			t.Errorf("request %d: unexpected result %+v", i, result)
+		}
+		clock.Advance(10 * time.Second)
+	}
+
+	// The first request happened 30 seconds ago and leaves the window in 30 seconds
+	result, _ := limiter.Allow(context.Background(), "1.2.3.4")
+	if result.Allowed || result.Remaining != 0 || result.RetryAfter != 30*time.Second {
+		t.Errorf("expected a rejection for 30s, but got %+v", result)
+	}
+
+	if result, _ := limiter.Allow(context.Background(), "5.6.7.8"); !result.Allowed {
+		t.Errorf("expected other keys to be limited separately, but got %+v", result)
+	}
+
+	clock.Advance(30 * time.Second)
+	if result, _ := limiter.Allow(context.Background(), "1.2.3.4"); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("expected the oldest request to leave the window, but got %+v", result)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := limiter.Allow(ctx, "1.2.3.4"); err == nil {
+		t.Error("expected an error with a canceled context, but got nil")
+	}
+}
+
+func TestTokenBucketLimiter(t *testing.T) {
+	redisClient, _ := newTestRedisClient(t)
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	limiter := redisClient.NewTokenBucketLimiter("tb:", 2, 4)
+	limiter.now = clock.Now
+
+	for i := 0; i < 4; i++ {
+		if result, _ := limiter.Allow(context.Background(), "sub"); !result.Allowed || result.Remaining != int64(3-i) {
+			t.Errorf("request %d: unexpected result %+v", i, result)
+		}
+	}
+
+	result, err := limiter.Allow(context.Background(), "sub")
+	if err != nil {
+		t.Fatalf("Allow err, msg: %v", err)
+	}
+	if result.Allowed || result.RetryAfter != 500*time.Millisecond || result.ResetAfter != 2*time.Second {
+		t.Errorf("expected a rejection for 500ms, but got %+v", result)
+	}
+
+	// Two tokens per second, 750ms refill one and a half
+	clock.Advance(750 * time.Millisecond)
+	if result, _ := limiter.Allow(context.Background(), "sub"); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("expected a refilled token, but got %+v", result)
+	}
+	if result, _ := limiter.Allow(context.Background(), "sub"); result.Allowed || result.RetryAfter != 250*time.Millisecond {
+		t.Errorf("expected a rejection for 250ms, but got %+v", result)
+	}
+
+	clock.Advance(time.Hour)
+	if result, _ := limiter.Allow(context.Background(), "sub"); !result.Allowed || result.Remaining != 3 {
+		t.Errorf("expected the bucket to be capped at its size, but got %+v", result)
+	}
+}
@@ -0,0 +1,184 @@
+package gm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var ErrScriptNotFound = errors.New("redis script not registered")
+
+// Lua scripts of a client by name, shared by its context views and pipelines
+type scriptRegistry struct {
+	mu      sync.RWMutex
+	scripts map[string]*redis.Script
+}
+
+func newScriptRegistry() *scriptRegistry {
+	return &scriptRegistry{scripts: make(map[string]*redis.Script)}
+}
+
+func (registry *scriptRegistry) get(name string) (*redis.Script, error) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	script, ok := registry.scripts[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrScriptNotFound, name)
+	}
+	return script, nil
+}
+
+/*
+Register the script under name and load it into the script cache of the server, a registered name is replaced
+
+	redisClient.RegisterScript("incr_cap", `
+	local value = redis.call("INCR", KEYS[1])
+	if value > tonumber(ARGV[1]) then
+		redis.call("DECR", KEYS[1])
+		return 0
+	end
+	return value`)
+*/
+func (redisClient *RedisClient) RegisterScript(name, src string) error {
+	script := redis.NewScript(src)
+	if err := script.Load(redisClient.Context, redisClient.cmd).Err(); err != nil {
+		return fmt.Errorf("redis.ScriptLoad %s err %v", name, err)
+	}
+
+	redisClient.scripts.mu.Lock()
+	redisClient.scripts.scripts[name] = script
+	redisClient.scripts.mu.Unlock()
+	return nil
+}
+
+/*
+Register every .lua file of fsys, the name of a script is its path without the extension
+
+	//go:embed scripts
+	var scripts embed.FS
+
+	err := redisClient.LoadScripts(scripts) // scripts/transfer.lua is run as "scripts/transfer"
+
+	sub, _ := fs.Sub(scripts, "scripts")
+	err := redisClient.LoadScripts(sub) // run as "transfer"
+*/
+func (redisClient *RedisClient) LoadScripts(fsys fs.FS) error {
+	var files []string
+	err := fs.WalkDir(fsys, ".", func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && path.Ext(file) == ".lua" {
+			files = append(files, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("fs.WalkDir err %v", err)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		src, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return fmt.Errorf("fs.ReadFile %s err %v", file, err)
+		}
+		if err := redisClient.RegisterScript(strings.TrimSuffix(file, ".lua"), string(src)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Names of the registered scripts, sorted
+func (redisClient *RedisClient) ScriptNames() []string {
+	redisClient.scripts.mu.RLock()
+	defer redisClient.scripts.mu.RUnlock()
+
+	names := make([]string, 0, len(redisClient.scripts.scripts))
+	for name := range redisClient.scripts.scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run the registered script with EVALSHA, it is sent again with EVAL when the server lost its script cache
+func (redisClient *redisCommands) RunScript(name string, keys []string, args ...interface{}) *redis.Cmd {
+	script, err := redisClient.scripts.get(name)
+	if err != nil {
+		cmd := redis.NewCmd(redisClient.Context)
+		cmd.SetErr(err)
+		return cmd
+	}
+	return script.Run(redisClient.Context, redisClient.cmd, keys, args...)
+}
+
+// Queue the registered script, pipelines send it with EVAL since a NOSCRIPT can not be retried after the EXEC
+func (pipeline *redisPipelineCommands) RunScript(name string, keys []string, args ...interface{}) *redis.Cmd {
+	script, err := pipeline.scripts.get(name)
+	if err != nil {
+		cmd := redis.NewCmd(pipeline.Context)
+		cmd.SetErr(err)
+		return cmd
+	}
+	return script.Eval(pipeline.Context, pipeline.tx, keys, args...)
+}
+
+/*
+Decode the reply of a script into T, integers, floats, strings, booleans and slices of them are converted,
+any other T is decoded from a JSON string such as the result of cjson.encode
+
+	remaining, err := gm.ScriptResult[int64](redisClient.RunScript("incr_cap", []string{"quota:1"}, 100))
+*/
+func ScriptResult[T any](cmd *redis.Cmd) (T, error) {
+	var result T
+	var value interface{}
+	var err error
+
+	switch any(result).(type) {
+	case int64:
+		value, err = cmd.Int64()
+	case int:
+		value, err = cmd.Int()
+	case uint64:
+		value, err = cmd.Uint64()
+	case float64:
+		value, err = cmd.Float64()
+	case string:
+		value, err = cmd.Text()
+	case bool:
+		value, err = cmd.Bool()
+	case []int64:
+		value, err = cmd.Int64Slice()
+	case []float64:
+		value, err = cmd.Float64Slice()
+	case []string:
+		value, err = cmd.StringSlice()
+	case []bool:
+		value, err = cmd.BoolSlice()
+	case []interface{}:
+		value, err = cmd.Slice()
+	default:
+		var text string
+		if text, err = cmd.Text(); err != nil {
+			return result, err
+		}
+		if err := json.Unmarshal([]byte(text), &result); err != nil {
+			return result, fmt.Errorf("json.Unmarshal script result err %v", err)
+		}
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	return value.(T), nil
+}
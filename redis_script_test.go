@@ -0,0 +1,79 @@
+package gm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+var testScripts = fstest.MapFS{
+	"incr_cap.lua": {Data: []byte(`
+local value = redis.call("INCR", KEYS[1])
+if value > tonumber(ARGV[1]) then
+	redis.call("DECR", KEYS[1])
+	return 0
+end
+return value`)},
+	"account/summary.lua": {Data: []byte(`return cjson.encode({id = ARGV[1], balance = tonumber(redis.call("GET", KEYS[1]))})`)},
+	"keys.lua":            {Data: []byte(`return KEYS`)},
+	"README.md":           {Data: []byte("not a script")},
+}
+
+func TestRedisScripts(t *testing.T) {
+	server := miniredis.RunT(t)
+	redisClient, err := InitRedisWithOptions(context.Background(), &RedisOptions{Addrs: []string{server.Addr()}, Scripts: testScripts})
+	if err != nil {
+		t.Fatalf("InitRedisWithOptions err, msg: %v", err)
+	}
+	defer redisClient.Close()
+
+	if names := redisClient.ScriptNames(); fmt.Sprint(names) != "[account/summary incr_cap keys]" {
+		t.Errorf("unexpected scripts %v", names)
+	}
+	if loaded, _ := redisClient.Client().ScriptExists(context.Background(), redisClient.scripts.scripts["incr_cap"].Hash()).Result(); !loaded[0] {
+		t.Error("expected the script to be loaded on connect")
+	}
+
+	for run, expected := range []int64{1, 2, 0} {
+		value, err := ScriptResult[int64](redisClient.RunScript("incr_cap", []string{"quota"}, 2))
+		if err != nil {
+			t.Fatalf("RunScript err, msg: %v", err)
+		}
+		if value != expected {
+			t.Errorf("expected %d on run %d, but got %d", expected, run+1, value)
+		}
+	}
+
+	// The script is sent again once the server forgot it
+	redisClient.Client().ScriptFlush(context.Background())
+	redisClient.Set("balance", 42, 0)
+	type summary struct {
+		ID      string `json:"id"`
+		Balance int64  `json:"balance"`
+	}
+	account, err := ScriptResult[summary](redisClient.RunScript("account/summary", []string{"balance"}, "acc-1"))
+	if err != nil || account.ID != "acc-1" || account.Balance != 42 {
+		t.Errorf("unexpected summary %+v %v", account, err)
+	}
+
+	pipeline := redisClient.NewTxPipeline()
+	keys := pipeline.RunScript("keys", []string{"a", "b"})
+	pipeline.Incr("counter")
+	if _, err := pipeline.Commit(); err != nil {
+		t.Fatalf("Commit err, msg: %v", err)
+	}
+	if values, err := ScriptResult[[]string](keys); err != nil || fmt.Sprint(values) != "[a b]" {
+		t.Errorf("unexpected keys %v %v", values, err)
+	}
+
+	if _, err := ScriptResult[int64](redisClient.RunScript("missing", nil)); !errors.Is(err, ErrScriptNotFound) {
+		t.Errorf("expected ErrScriptNotFound, but got %v", err)
+	}
+	if err := redisClient.RegisterScript("broken", "return ("); err == nil {
+		t.Error("expected an error for an invalid script")
+	}
+}
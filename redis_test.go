@@ -0,0 +1,275 @@
+package gm
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// Write a self-signed certificate for 127.0.0.1 to dir, returns the certificate and key files
+func writeTestCertificate(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey err, msg: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate err, msg: %v", err)
+	}
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	return certFile, keyFile
+}
+
+func TestInitRedisWithOptions(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+
+	if _, err := InitRedisWithOptions(ctx, &RedisOptions{}); err == nil {
+		t.Error("expected an error without addresses")
+	}
+
+	single, err := InitRedisWithOptions(ctx, &RedisOptions{Addrs: []string{server.Addr()}, PoolSize: 2, ReadTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("InitRedisWithOptions err, msg: %v", err)
+	}
+	defer single.Close()
+	if client, ok := single.NodeClient(); !ok || client != single.Client() {
+		t.Error("expected a single node client")
+	}
+	single.Set("mode", "single", 0)
+
+	cluster, err := InitRedisCluster(ctx, "", "", server.Addr())
+	if err != nil {
+		t.Fatalf("InitRedisCluster err, msg: %v", err)
+	}
+	defer cluster.Close()
+	if _, ok := cluster.NodeClient(); ok || cluster.UniversalClient() == nil {
+		t.Error("expected a cluster client")
+	}
+	if cluster.Client() != nil {
+		t.Error("expected Client to be nil on a cluster")
+	}
+
+	// The existing methods work the same on every mode
+	if value, _ := cluster.Get("mode").Result(); value != "single" {
+		t.Errorf("expected single, but got %s", value)
+	}
+	pipeline := cluster.NewTxPipeline()
+	pipeline.Pipeliner().Incr(ctx, "{user}:visits")
+	pipeline.Pipeliner().Incr(ctx, "{user}:visits")
+	if _, err := pipeline.Pipeliner().Exec(ctx); err != nil {
+		t.Fatalf("Exec err, msg: %v", err)
+	}
+	if visits, _ := single.Get("{user}:visits").Int(); visits != 2 {
+		t.Errorf("expected 2 visits, but got %d", visits)
+	}
+	lock, err := cluster.TryLock("lock:job", nil)
+	if err != nil {
+		t.Fatalf("TryLock err, msg: %v", err)
+	}
+	lock.Unlock()
+
+	addr := server.Addr()
+	server.Close()
+	if _, err := InitRedisWithOptions(ctx, &RedisOptions{Addrs: []string{addr}, DialTimeout: 100 * time.Millisecond, MaxRetries: -1}); err == nil {
+		t.Error("expected an error when the server is down")
+	}
+}
+
+// Minimal sentinel answering the commands of the go-redis failover client with the address of master
+func newTestSentinel(t *testing.T, master string) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen err, msg: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	host, port, _ := net.SplitHostPort(master)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					args, err := readTestRESPCommand(reader)
+					if err != nil {
+						return
+					}
+					switch strings.ToLower(strings.Join(args[:min(len(args), 2)], " ")) {
+					case "sentinel get-master-addr-by-name":
+						fmt.Fprintf(conn, "*2\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(host), host, len(port), port)
+					case "sentinel sentinels":
+						fmt.Fprint(conn, "*0\r\n")
+					default:
+						if strings.EqualFold(args[0], "subscribe") {
+							for i, channel := range args[1:] {
+								fmt.Fprintf(conn, "*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:%d\r\n", len(channel), channel, i+1)
+							}
+							continue
+						}
+						fmt.Fprint(conn, "+PONG\r\n")
+					}
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func readTestRESPCommand(reader *bufio.Reader) ([]string, error) {
+	var count int
+	if _, err := fmt.Fscanf(reader, "*%d\r\n", &count); err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		var size int
+		if _, err := fmt.Fscanf(reader, "$%d\r\n", &size); err != nil {
+			return nil, err
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+func TestInitRedisSentinel(t *testing.T) {
+	server := miniredis.RunT(t)
+	sentinel := newTestSentinel(t, server.Addr())
+
+	redisClient, err := InitRedisSentinel(context.Background(), "mymaster", "", 0, sentinel)
+	if err != nil {
+		t.Fatalf("InitRedisSentinel err, msg: %v", err)
+	}
+	defer redisClient.Close()
+
+	if _, ok := redisClient.NodeClient(); !ok {
+		t.Error("expected the sentinel master client")
+	}
+	redisClient.Set("mode", "sentinel", 0)
+	if value, _ := server.Get("mode"); value != "sentinel" {
+		t.Errorf("expected the command to reach the master, but got %q", value)
+	}
+}
+
+func TestInitRedisWithTLS(t *testing.T) {
+	dir := t.TempDir()
+	serverCert, serverKey := writeTestCertificate(t, dir, "server")
+	clientCert, clientKey := writeTestCertificate(t, dir, "client")
+
+	// The server only accepts clients presenting the client certificate
+	serverConfig, err := NewRedisTLSConfig(clientCert, serverCert, serverKey)
+	if err != nil {
+		t.Fatalf("NewRedisTLSConfig err, msg: %v", err)
+	}
+	serverConfig.ClientCAs, serverConfig.RootCAs = serverConfig.RootCAs, nil
+	serverConfig.ClientAuth = tls.RequireAndVerifyClientCert
+
+	server, err := miniredis.RunTLS(serverConfig)
+	if err != nil {
+		t.Fatalf("miniredis.RunTLS err, msg: %v", err)
+	}
+	defer server.Close()
+
+	ctx := context.Background()
+	clientConfig, err := NewRedisTLSConfig(serverCert, clientCert, clientKey)
+	if err != nil {
+		t.Fatalf("NewRedisTLSConfig err, msg: %v", err)
+	}
+	redisClient, err := InitRedisWithOptions(ctx, &RedisOptions{Addrs: []string{server.Addr()}, TLSConfig: clientConfig})
+	if err != nil {
+		t.Fatalf("InitRedisWithOptions err, msg: %v", err)
+	}
+	defer redisClient.Close()
+	if err := redisClient.Set("tls", "on", 0).Err(); err != nil {
+		t.Errorf("Set err, msg: %v", err)
+	}
+
+	withoutCert, _ := NewRedisTLSConfig(serverCert, "", "")
+	if _, err := InitRedisWithOptions(ctx, &RedisOptions{Addrs: []string{server.Addr()}, TLSConfig: withoutCert, MaxRetries: -1}); err == nil {
+		t.Error("expected the server to reject a client without certificate")
+	}
+
+	if _, err := NewRedisTLSConfig(filepath.Join(dir, "missing.crt"), "", ""); err == nil {
+		t.Error("expected an error for a missing CA file")
+	}
+}
+
+func TestRedisWithContext(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	// Cancelling the init context does not break the client
+	initCtx, cancelInit := context.WithCancel(context.Background())
+	redisClient, err := InitRedisFromURL(initCtx, "redis://"+server.Addr())
+	if err != nil {
+		t.Fatalf("InitRedisFromURL err, msg: %v", err)
+	}
+	defer redisClient.Close()
+	cancelInit()
+	if err := redisClient.Set("key", "value", 0).Err(); err != nil {
+		t.Errorf("expected the client to outlive the init context, but got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	view := redisClient.WithContext(ctx)
+	if value, _ := view.Get("key").Result(); value != "value" {
+		t.Errorf("expected value, but got %s", value)
+	}
+	cancel()
+	if err := view.Get("key").Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, but got %v", err)
+	}
+
+	pipeline := redisClient.NewTxPipeline()
+	pipeline.Incr("counter")
+	if _, err := pipeline.WithContext(ctx).Commit(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, but got %v", err)
+	}
+	if _, err := redisClient.Get("key").Result(); err != nil {
+		t.Errorf("expected the base client to keep working, but got %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package gm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	mathrand "math/rand/v2"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	defaultWatchMaxAttempts = 10
+	defaultWatchMinBackoff  = 5 * time.Millisecond
+	defaultWatchMaxBackoff  = 200 * time.Millisecond
+)
+
+/*
+Pipeline without MULTI/EXEC, commands are sent in one round trip but other clients may run between them.
+It has the commands of RedisTxPipeline, Commit sends the queued commands.
+
+	pipeline := redisClient.NewPipeline()
+	profile := pipeline.HGetAll("profile:1")
+	visits := pipeline.Get("visits:1")
+	pipeline.Commit()
+*/
+type RedisPipeline struct {
+	redisPipelineCommands
+}
+
+func (redisClient *RedisClient) NewPipeline() *RedisPipeline {
+	return &RedisPipeline{redisPipelineCommands{Context: redisClient.Context, tx: redisClient.cmd.Pipeline(), scripts: redisClient.scripts}}
+}
+
+// Queue the following commands and run Commit with ctx
+func (pipeline *RedisPipeline) WithContext(ctx context.Context) *RedisPipeline {
+	return &RedisPipeline{redisPipelineCommands{Context: ctx, tx: pipeline.tx, scripts: pipeline.scripts}}
+}
+
+type WatchOptions struct {
+	MaxAttempts int           // runs of fn before giving up, 10 when zero
+	MinBackoff  time.Duration // delay before the second run, 5 milliseconds when zero
+	MaxBackoff  time.Duration // retry delay cap, 200 milliseconds when zero
+}
+
+func (options *WatchOptions) withDefaults() WatchOptions {
+	opts := WatchOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultWatchMaxAttempts
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = defaultWatchMinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(defaultWatchMaxBackoff, opts.MinBackoff)
+	}
+	return opts
+}
+
+/*
+A WATCH in progress, its commands run on the watched connection and Pipelined queues the writes.
+It only has the data commands: locks, Publish, queues and the pool of the client are not available inside a Watch.
+*/
+type RedisTx struct {
+	redisCommands
+	tx *redis.Tx
+}
+
+func (tx *RedisTx) Tx() *redis.Tx {
+	return tx.tx
+}
+
+// Run the commands queued by fn with MULTI/EXEC, fails with redis.TxFailedErr when a watched key changed
+func (tx *RedisTx) Pipelined(fn func(pipeline *RedisTxPipeline) error) ([]redis.Cmder, error) {
+	pipeline := &RedisTxPipeline{redisPipelineCommands{Context: tx.Context, tx: tx.tx.TxPipeline(), scripts: tx.scripts}}
+	if err := fn(pipeline); err != nil {
+		return nil, err
+	}
+	return pipeline.Commit()
+}
+
+/*
+Check-and-set on keys, fn runs again while another client modifies a watched key before the EXEC.
+On a cluster every key must be in the same slot.
+
+	err := redisClient.Watch(ctx, func(tx *gm.RedisTx) error {
+		balance, err := tx.Get("balance:1").Int64()
+		if err != nil {
+			return err
+		}
+		if balance < amount {
+			return ErrInsufficientBalance
+		}
+
+		_, err = tx.Pipelined(func(pipeline *gm.RedisTxPipeline) error {
+			pipeline.DecrBy("balance:1", amount)
+			return nil
+		})
+		return err
+	}, "balance:1")
+*/
+func (redisClient *RedisClient) Watch(ctx context.Context, fn func(tx *RedisTx) error, keys ...string) error {
+	return redisClient.WatchWithOptions(ctx, nil, fn, keys...)
+}
+
+// Watch with a custom attempt limit and backoff, the error wraps redis.TxFailedErr when every attempt conflicted
+func (redisClient *RedisClient) WatchWithOptions(ctx context.Context, options *WatchOptions, fn func(tx *RedisTx) error, keys ...string) error {
+	opts := options.withDefaults()
+	backoff := opts.MinBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := redisClient.client.Watch(ctx, func(tx *redis.Tx) error {
+			return fn(&RedisTx{redisCommands: redisCommands{Context: ctx, cmd: tx, scripts: redisClient.scripts}, tx: tx})
+		}, keys...)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+		if attempt >= opts.MaxAttempts {
+			return fmt.Errorf("redis.Watch %v gave up after %d attempts: %w", keys, attempt, err)
+		}
+
+		timer := time.NewTimer(time.Duration(mathrand.Int64N(int64(backoff)) + 1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, opts.MaxBackoff)
+	}
+}
@@ -0,0 +1,110 @@
+package gm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func watchIncr(redisClient *RedisClient, ctx context.Context, key string, options *WatchOptions, before func()) error {
+	return redisClient.WatchWithOptions(ctx, options, func(tx *RedisTx) error {
+		value, err := tx.Get(key).Int64()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		before()
+
+		_, err = tx.Pipelined(func(pipeline *RedisTxPipeline) error {
+			pipeline.Set(key, value+1, 0)
+			return nil
+		})
+		return err
+	}, key)
+}
+
+func TestRedisWatch(t *testing.T) {
+	redisClient, _ := newTestRedisClient(t)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := watchIncr(redisClient, ctx, "counter", &WatchOptions{MaxAttempts: 100}, func() {}); err != nil {
+				t.Errorf("Watch err, msg: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if value, _ := redisClient.Get("counter").Int(); value != 10 {
+		t.Errorf("expected 10 increments, but got %d", value)
+	}
+
+	// Another client changes the key between the read and the EXEC of every attempt
+	attempts := 0
+	err := watchIncr(redisClient, ctx, "counter", &WatchOptions{MaxAttempts: 3}, func() {
+		attempts++
+		redisClient.Incr("counter")
+	})
+	if !errors.Is(err, redis.TxFailedErr) || attempts != 3 {
+		t.Errorf("expected redis.TxFailedErr after 3 attempts, but got %v after %d", err, attempts)
+	}
+
+	// A conflict on the first attempt only is retried
+	attempts = 0
+	err = watchIncr(redisClient, ctx, "counter", nil, func() {
+		if attempts++; attempts == 1 {
+			redisClient.Incr("counter")
+		}
+	})
+	if err != nil || attempts != 2 {
+		t.Errorf("expected success on attempt 2, but got %v after %d", err, attempts)
+	}
+	if value, _ := redisClient.Get("counter").Int(); value != 15 {
+		t.Errorf("expected 15, but got %d", value)
+	}
+
+	callbackErr := errors.New("insufficient balance")
+	if err := redisClient.Watch(ctx, func(tx *RedisTx) error { return callbackErr }, "counter"); err != callbackErr {
+		t.Errorf("expected the callback error, but got %v", err)
+	}
+}
+
+func TestRedisPipeline(t *testing.T) {
+	redisClient, _ := newTestRedisClient(t)
+	redisClient.Set("a", "1", 0)
+	redisClient.HSet("h", "f", "v")
+
+	pipeline := redisClient.NewPipeline()
+	a := pipeline.Get("a")
+	h := pipeline.HGetAll("h")
+	missing := pipeline.Get("missing")
+	if _, err := pipeline.Commit(); err != redis.Nil {
+		t.Errorf("expected redis.Nil of the missing key, but got %v", err)
+	}
+	if a.Val() != "1" || h.Val()["f"] != "v" || missing.Err() != redis.Nil {
+		t.Errorf("unexpected pipeline results %v %v %v", a.Val(), h.Val(), missing.Err())
+	}
+}
+
+func TestRedisTxSurface(t *testing.T) {
+	// Helpers of the client would run on the pool instead of the watched connection
+	tx := reflect.TypeOf(&RedisTx{})
+	for _, name := range []string{"Close", "UniversalClient", "TryLock", "Publish", "NewTxPipeline"} {
+		if _, ok := tx.MethodByName(name); ok {
+			t.Errorf("expected RedisTx to have no %s", name)
+		}
+	}
+	if _, ok := tx.MethodByName("HGetAll"); !ok {
+		t.Error("expected RedisTx to have the data commands")
+	}
+
+	if reflect.TypeOf(RedisPipeline{}) == reflect.TypeOf(RedisTxPipeline{}) {
+		t.Error("expected RedisPipeline to be a distinct type")
+	}
+}